@@ -4,6 +4,7 @@ go 1.12
 
 require (
 	github.com/go-playground/ansi v2.1.0+incompatible
+	github.com/golang/snappy v0.0.1
 	github.com/google/go-cmp v0.3.0 // indirect
 	github.com/orbs-network/go-mock v0.0.0-20180813130752-890a1ee8d0a1
 	github.com/orbs-network/gojay v1.3.0
@@ -0,0 +1,354 @@
+// Copyright 2019 the orbs-network-go authors
+// This file is part of the orbs-network-go library in the Orbs project.
+//
+// This source code is licensed under the MIT license found in the LICENSE file in the root directory of this source tree.
+// The above notice should be included in all copies or substantial portions of the software.
+
+package log
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// runtimeFilter holds the level threshold and temporary filters that the admin handler changes while rows are being logged
+type runtimeFilter struct {
+	levelThreshold
+
+	lock      sync.RWMutex
+	temporary map[string]*temporaryFilter
+}
+
+type temporaryFilter struct {
+	description string
+	filter      Filter
+	expires     time.Time
+}
+
+func (f *runtimeFilter) Allows(level string, message string, fields []*Field) bool {
+	if !f.levelThreshold.allows(level) {
+		return false
+	}
+
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+
+	if len(f.temporary) == 0 {
+		return true
+	}
+
+	now := time.Now()
+	for _, t := range f.temporary {
+		if now.Before(t.expires) && !t.filter.Allows(level, message, fields) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (f *runtimeFilter) addTemporary(name string, t *temporaryFilter) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.removeExpired()
+	f.temporary[name] = t
+}
+
+func (f *runtimeFilter) removeTemporary(name string) bool {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.removeExpired()
+	_, ok := f.temporary[name]
+	delete(f.temporary, name)
+	return ok
+}
+
+// assumes lock
+func (f *runtimeFilter) removeExpired() {
+	now := time.Now()
+	for name, t := range f.temporary {
+		if !now.Before(t.expires) {
+			delete(f.temporary, name)
+		}
+	}
+}
+
+type adminHandler struct {
+	logger  Logger
+	runtime *runtimeFilter
+
+	lock        sync.RWMutex
+	conditional map[string]ConditionalFilter
+}
+
+// NewAdminHandler serves the state of logger as JSON on GET, and lets operators change it at runtime:
+//
+//	PUT    /level?level=warn[&output=0]                 rows below warn are dropped, by the logger or by one of its LeveledOutputs
+//	DELETE /level                                       removes the logger's threshold
+//	PUT    /conditional?name=debug-p2p&on=true          toggles a filter registered with WithConditionalFilter
+//	PUT    /temporary?name=n&ttl=10m&kind=match-field&key=request-id&value=abc
+//	DELETE /temporary?name=n
+//
+// temporary filters are one of min-level (level), match-field and exclude-field (key, value of a string field),
+// ignore-messages and ignore-errors (pattern), only-errors or discard-all, and are removed once ttl passes.
+// the state is served on GET of any of these paths, and of the path the handler is mounted on if it ends with a slash.
+// the handler adds a filter of its own to logger, which is not safe while rows are being logged, so it must be created
+// before logger is used, and before loggers are derived from logger with WithTags
+func NewAdminHandler(logger Logger) *adminHandler {
+	h := &adminHandler{
+		logger:      logger,
+		runtime:     &runtimeFilter{temporary: make(map[string]*temporaryFilter)},
+		conditional: make(map[string]ConditionalFilter),
+	}
+	logger.WithFilters(h.runtime)
+
+	return h
+}
+
+// WithConditionalFilter makes filter available for toggling under name; it is up to the caller to add it to a logger or output
+func (h *adminHandler) WithConditionalFilter(name string, filter ConditionalFilter) *adminHandler {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	h.conditional[name] = filter
+	return h
+}
+
+type adminState struct {
+	Level       string           `json:"level"`
+	Filters     []string         `json:"filters"`
+	Outputs     []adminOutput    `json:"outputs"`
+	Conditional map[string]bool  `json:"conditional"`
+	Temporary   []adminTemporary `json:"temporary"`
+}
+
+type adminOutput struct {
+	Index   int    `json:"index"`
+	Type    string `json:"type"`
+	Leveled bool   `json:"leveled"`
+}
+
+type adminTemporary struct {
+	Name    string    `json:"name"`
+	Filter  string    `json:"filter"`
+	Expires time.Time `json:"expires"`
+}
+
+func (h *adminHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var err error
+
+	// the handler may be mounted under any prefix, so only the last path element is routed
+	resource := path.Base(r.URL.Path)
+	if r.URL.Path == "" || strings.HasSuffix(r.URL.Path, "/") {
+		resource = ""
+	}
+
+	switch {
+	case resource != "" && resource != "level" && resource != "conditional" && resource != "temporary":
+		http.NotFound(w, r)
+		return
+	case r.Method == http.MethodGet:
+	case resource == "level":
+		err = h.serveLevel(r)
+	case resource == "conditional":
+		err = h.serveConditional(r)
+	case resource == "temporary":
+		err = h.serveTemporary(r)
+	default:
+		http.Error(w, fmt.Sprintf("unsupported method %s", r.Method), http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(h.state())
+}
+
+func (h *adminHandler) serveLevel(r *http.Request) error {
+	var threshold interface{ SetMinLevel(level Level) } = h.runtime
+	if index := r.FormValue("output"); index != "" {
+		output, err := h.output(index)
+		if err != nil {
+			return err
+		}
+		threshold = output
+	}
+
+	switch r.Method {
+	case http.MethodPut, http.MethodPost:
+		level, err := ParseLevel(r.FormValue("level"))
+		if err != nil {
+			return err
+		}
+		threshold.SetMinLevel(level)
+	case http.MethodDelete:
+		if threshold != h.runtime {
+			return errors.Errorf("thresholds of outputs can only be changed, not removed")
+		}
+		h.runtime.clearMinLevel()
+	default:
+		return errors.Errorf("unsupported method %s", r.Method)
+	}
+
+	return nil
+}
+
+func (h *adminHandler) output(index string) (LeveledOutput, error) {
+	i, err := strconv.Atoi(index)
+	outputs := h.logger.Outputs()
+	if err != nil || i < 0 || i >= len(outputs) {
+		return nil, errors.Errorf("no output at index %s", index)
+	}
+
+	output, ok := outputs[i].(LeveledOutput)
+	if !ok {
+		return nil, errors.Errorf("output %d of type %T has no level threshold", i, outputs[i])
+	}
+
+	return output, nil
+}
+
+func (h *adminHandler) serveConditional(r *http.Request) error {
+	if r.Method != http.MethodPut && r.Method != http.MethodPost {
+		return errors.Errorf("unsupported method %s", r.Method)
+	}
+
+	h.lock.RLock()
+	filter, ok := h.conditional[r.FormValue("name")]
+	h.lock.RUnlock()
+	if !ok {
+		return errors.Errorf("no conditional filter named %s", r.FormValue("name"))
+	}
+
+	on, err := strconv.ParseBool(r.FormValue("on"))
+	if err != nil {
+		return errors.Errorf("invalid value for on: %s", r.FormValue("on"))
+	}
+
+	if on {
+		filter.On()
+	} else {
+		filter.Off()
+	}
+
+	return nil
+}
+
+func (h *adminHandler) serveTemporary(r *http.Request) error {
+	name := r.FormValue("name")
+	if name == "" {
+		return errors.Errorf("temporary filters need a name")
+	}
+
+	switch r.Method {
+	case http.MethodPut, http.MethodPost:
+		ttl, err := time.ParseDuration(r.FormValue("ttl"))
+		if err != nil || ttl <= 0 {
+			return errors.Errorf("invalid ttl %s", r.FormValue("ttl"))
+		}
+
+		t, err := parseTemporaryFilter(r)
+		if err != nil {
+			return err
+		}
+		t.expires = time.Now().Add(ttl)
+
+		h.runtime.addTemporary(name, t)
+	case http.MethodDelete:
+		if !h.runtime.removeTemporary(name) {
+			return errors.Errorf("no temporary filter named %s", name)
+		}
+	default:
+		return errors.Errorf("unsupported method %s", r.Method)
+	}
+
+	return nil
+}
+
+func parseTemporaryFilter(r *http.Request) (*temporaryFilter, error) {
+	kind := r.FormValue("kind")
+	key, value, pattern := r.FormValue("key"), r.FormValue("value"), r.FormValue("pattern")
+
+	if kind == "ignore-messages" || kind == "ignore-errors" {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return nil, errors.Errorf("invalid pattern %s: %s", pattern, err)
+		}
+	}
+
+	switch kind {
+	case "min-level":
+		level, err := ParseLevel(r.FormValue("level"))
+		if err != nil {
+			return nil, err
+		}
+		return &temporaryFilter{description: fmt.Sprintf("%s %s", kind, level), filter: MinLevel(level)}, nil
+	case "match-field":
+		return &temporaryFilter{description: fmt.Sprintf("%s %s=%s", kind, key, value), filter: MatchField(String(key, value))}, nil
+	case "exclude-field":
+		return &temporaryFilter{description: fmt.Sprintf("%s %s=%s", kind, key, value), filter: ExcludeField(String(key, value))}, nil
+	case "ignore-messages":
+		return &temporaryFilter{description: fmt.Sprintf("%s %s", kind, pattern), filter: IgnoreMessagesMatching(pattern)}, nil
+	case "ignore-errors":
+		return &temporaryFilter{description: fmt.Sprintf("%s %s", kind, pattern), filter: IgnoreErrorsMatching(pattern)}, nil
+	case "only-errors":
+		return &temporaryFilter{description: kind, filter: OnlyErrors()}, nil
+	case "discard-all":
+		return &temporaryFilter{description: kind, filter: DiscardAll()}, nil
+	}
+
+	return nil, errors.Errorf("unknown filter kind %s", kind)
+}
+
+func (h *adminHandler) state() *adminState {
+	state := &adminState{Conditional: make(map[string]bool)}
+
+	if level, ok := h.runtime.threshold(); ok {
+		state.Level = level.String()
+	}
+
+	for _, f := range h.logger.Filters() {
+		state.Filters = append(state.Filters, fmt.Sprintf("%T", f))
+	}
+
+	for i, o := range h.logger.Outputs() {
+		_, leveled := o.(LeveledOutput)
+		state.Outputs = append(state.Outputs, adminOutput{i, fmt.Sprintf("%T", o), leveled})
+	}
+
+	// filters that can't tell whether they are on are left out
+	h.lock.RLock()
+	for name, f := range h.conditional {
+		if toggled, ok := f.(toggledFilter); ok {
+			state.Conditional[name] = toggled.IsOn()
+		}
+	}
+	h.lock.RUnlock()
+
+	h.runtime.lock.Lock()
+	h.runtime.removeExpired()
+	for name, t := range h.runtime.temporary {
+		state.Temporary = append(state.Temporary, adminTemporary{name, t.description, t.expires})
+	}
+	h.runtime.lock.Unlock()
+	sort.Slice(state.Temporary, func(i, j int) bool {
+		return state.Temporary[i].Name < state.Temporary[j].Name
+	})
+
+	return state
+}
@@ -0,0 +1,226 @@
+// Copyright 2019 the orbs-network-go authors
+// This file is part of the orbs-network-go library in the Orbs project.
+//
+// This source code is licensed under the MIT license found in the LICENSE file in the root directory of this source tree.
+// The above notice should be included in all copies or substantial portions of the software.
+
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func adminRequest(t *testing.T, h http.Handler, method string, url string) (int, *adminState) {
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(method, url, nil))
+
+	state := &adminState{}
+	if rec.Code == http.StatusOK {
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), state))
+	}
+
+	return rec.Code, state
+}
+
+func TestAdminHandler_ListsFiltersAndOutputs(t *testing.T) {
+	logger := GetLogger().
+		WithOutput(NewFormattingOutput(ioutil.Discard, NewJsonFormatter()), NewTestOutput(t, NewJsonFormatter())).
+		WithFilters(OnlyErrors())
+	h := NewAdminHandler(logger).WithConditionalFilter("no-metrics", NewConditionalFilter(true, OnlyMetrics()))
+
+	code, state := adminRequest(t, h, "GET", "/")
+
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, []string{"*log.onlyErrors", "*log.runtimeFilter"}, state.Filters)
+	require.Equal(t, []adminOutput{{0, "*log.basicOutput", true}, {1, "*log.TestOutput", false}}, state.Outputs)
+	require.Equal(t, map[string]bool{"no-metrics": true}, state.Conditional)
+	require.Empty(t, state.Level)
+}
+
+func TestAdminHandler_ChangesLevelThreshold(t *testing.T) {
+	b := new(bytes.Buffer)
+	logger := GetLogger().WithOutput(NewFormattingOutput(b, nopFormatter{}))
+	h := NewAdminHandler(logger)
+
+	code, state := adminRequest(t, h, "PUT", "/admin/log/level?level=warn")
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, "warn", state.Level)
+
+	logger.Info("foo")
+	logger.Warn("bar")
+
+	code, state = adminRequest(t, h, "DELETE", "/admin/log/level")
+	require.Equal(t, http.StatusOK, code)
+	require.Empty(t, state.Level)
+
+	logger.Info("baz")
+	require.Equal(t, "bar\nbaz\n", b.String())
+}
+
+func TestAdminHandler_ChangesLevelThresholdOfOutput(t *testing.T) {
+	b := new(bytes.Buffer)
+	logger := GetLogger().WithOutput(NewFormattingOutput(b, nopFormatter{}))
+	h := NewAdminHandler(logger)
+
+	code, _ := adminRequest(t, h, "PUT", "/level?level=error&output=0")
+	require.Equal(t, http.StatusOK, code)
+
+	logger.Warn("foo")
+	logger.Error("bar")
+	require.Equal(t, "bar\n", b.String())
+
+	code, _ = adminRequest(t, h, "PUT", "/level?level=error&output=1")
+	require.Equal(t, http.StatusBadRequest, code)
+}
+
+func TestAdminHandler_TogglesConditionalFilters(t *testing.T) {
+	b := new(bytes.Buffer)
+	filter := NewConditionalFilter(false, OnlyErrors())
+	logger := GetLogger().WithOutput(NewFormattingOutput(b, nopFormatter{})).WithFilters(filter)
+	h := NewAdminHandler(logger).WithConditionalFilter("only-errors", filter)
+
+	code, state := adminRequest(t, h, "PUT", "/conditional?name=only-errors&on=true")
+	require.Equal(t, http.StatusOK, code)
+	require.True(t, state.Conditional["only-errors"])
+
+	logger.Info("foo")
+	logger.Error("bar")
+	require.Equal(t, "bar\n", b.String())
+
+	code, _ = adminRequest(t, h, "PUT", "/conditional?name=nope&on=true")
+	require.Equal(t, http.StatusBadRequest, code)
+}
+
+func TestAdminHandler_TemporaryFiltersExpire(t *testing.T) {
+	b := new(bytes.Buffer)
+	logger := GetLogger().WithOutput(NewFormattingOutput(b, nopFormatter{}))
+	h := NewAdminHandler(logger)
+
+	code, state := adminRequest(t, h, "PUT", "/temporary?name=abc&ttl=50ms&kind=match-field&key=request-id&value=abc")
+	require.Equal(t, http.StatusOK, code)
+	require.Len(t, state.Temporary, 1)
+	require.Equal(t, "match-field request-id=abc", state.Temporary[0].Filter)
+
+	logger.Info("foo", String("request-id", "abc"))
+	logger.Info("bar", String("request-id", "def"))
+	require.Equal(t, "foo\n", b.String())
+
+	time.Sleep(60 * time.Millisecond)
+	logger.Info("baz", String("request-id", "def"))
+	require.Equal(t, "foo\nbaz\n", b.String())
+
+	_, state = adminRequest(t, h, "GET", "/")
+	require.Empty(t, state.Temporary)
+}
+
+func TestAdminHandler_RemovesTemporaryFilters(t *testing.T) {
+	b := new(bytes.Buffer)
+	logger := GetLogger().WithOutput(NewFormattingOutput(b, nopFormatter{}))
+	h := NewAdminHandler(logger)
+
+	code, _ := adminRequest(t, h, "PUT", "/temporary?name=quiet&ttl=1h&kind=discard-all")
+	require.Equal(t, http.StatusOK, code)
+	logger.Error("foo")
+
+	code, _ = adminRequest(t, h, "DELETE", "/temporary?name=quiet")
+	require.Equal(t, http.StatusOK, code)
+	logger.Error("bar")
+
+	require.Equal(t, "bar\n", b.String())
+}
+
+func TestAdminHandler_RejectsInvalidRequests(t *testing.T) {
+	h := NewAdminHandler(GetLogger().WithOutput())
+
+	for _, url := range []string{
+		"/level?level=loud",
+		"/temporary?ttl=1h&kind=only-errors",
+		"/temporary?name=n&ttl=soon&kind=only-errors",
+		"/temporary?name=n&ttl=1h&kind=unknown",
+		"/temporary?name=n&ttl=1h&kind=ignore-messages&pattern=(",
+		"/conditional?name=n&on=maybe",
+	} {
+		code, _ := adminRequest(t, h, "PUT", url)
+		require.Equal(t, http.StatusBadRequest, code, url)
+	}
+
+	code, _ := adminRequest(t, h, "PUT", "/unknown")
+	require.Equal(t, http.StatusNotFound, code)
+}
+
+func TestAdminHandler_ServesStateOnlyOnKnownPaths(t *testing.T) {
+	h := NewAdminHandler(GetLogger().WithOutput(NewFormattingOutput(new(bytes.Buffer), NewJsonFormatter())))
+
+	for _, url := range []string{"/", "/admin/log/", "/admin/log/level", "/temporary"} {
+		code, _ := adminRequest(t, h, "GET", url)
+		require.Equal(t, http.StatusOK, code, url)
+	}
+
+	for _, url := range []string{"/favicon.ico", "/admin/log"} {
+		code, _ := adminRequest(t, h, "GET", url)
+		require.Equal(t, http.StatusNotFound, code, url)
+	}
+
+	code, _ := adminRequest(t, h, "PUT", "/")
+	require.Equal(t, http.StatusMethodNotAllowed, code)
+}
+
+type customConditionalFilter struct {
+	Filter
+}
+
+func (f *customConditionalFilter) On()  {}
+func (f *customConditionalFilter) Off() {}
+
+func TestAdminHandler_TogglesConditionalFiltersThatCantTellTheirState(t *testing.T) {
+	h := NewAdminHandler(GetLogger().WithOutput(NewFormattingOutput(new(bytes.Buffer), NewJsonFormatter()))).
+		WithConditionalFilter("custom", &customConditionalFilter{OnlyErrors()})
+
+	code, state := adminRequest(t, h, "PUT", "/conditional?name=custom&on=true")
+	require.Equal(t, http.StatusOK, code)
+	require.Empty(t, state.Conditional)
+}
+
+func TestAdminHandler_ChangesAreSafeWhileLogging(t *testing.T) {
+	filter := NewConditionalFilter(false, OnlyErrors())
+	logger := GetLogger().WithOutput(NewFormattingOutput(ioutil.Discard, nopFormatter{})).WithFilters(filter)
+	h := NewAdminHandler(logger).WithConditionalFilter("only-errors", filter)
+
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+					logger.Info("foo", String("request-id", "abc"))
+				}
+			}
+		}()
+	}
+
+	for i := 0; i < 20; i++ {
+		adminRequest(t, h, "PUT", "/level?level=warn")
+		adminRequest(t, h, "PUT", "/conditional?name=only-errors&on=true")
+		adminRequest(t, h, "PUT", "/temporary?name=abc&ttl=1h&kind=match-field&key=request-id&value=abc")
+		adminRequest(t, h, "DELETE", "/level")
+		adminRequest(t, h, "PUT", "/conditional?name=only-errors&on=false")
+		adminRequest(t, h, "DELETE", "/temporary?name=abc")
+	}
+
+	close(done)
+	wg.Wait()
+}
@@ -0,0 +1,279 @@
+// Copyright 2019 the orbs-network-go authors
+// This file is part of the orbs-network-go library in the Orbs project.
+//
+// This source code is licensed under the MIT license found in the LICENSE file in the root directory of this source tree.
+// The above notice should be included in all copies or substantial portions of the software.
+
+package log
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type DropPolicy int
+
+const (
+	// DropPolicyBlock makes Append wait until there is room in the queue
+	DropPolicyBlock DropPolicy = iota
+	// DropPolicyNewest discards the row being appended when the queue is full
+	DropPolicyNewest
+	// DropPolicyOldest discards the oldest queued row to make room for the row being appended
+	DropPolicyOldest
+	// DropPolicySample keeps one in every SampleRate rows appended while the queue is full, in place of the oldest queued row.
+	// with a SampleRate of 1 (the default) it behaves like DropPolicyOldest
+	DropPolicySample
+)
+
+const DEFAULT_ASYNC_QUEUE_SIZE = 1000
+const ASYNC_ERROR_QUEUE_SIZE = 100
+
+type AsyncOutputOptions struct {
+	QueueSize  int
+	DropPolicy DropPolicy
+	SampleRate int
+}
+
+type queuedRow struct {
+	onError   func(err error)
+	timestamp time.Time
+	level     string
+	message   string
+	fields    []*Field
+}
+
+type asyncOutput struct {
+	inner   Output
+	policy  DropPolicy
+	sample  int
+	filters and
+	levelThreshold
+
+	lock       *sync.Mutex
+	cond       *sync.Cond
+	queue      []*queuedRow // ring buffer
+	head       int
+	count      int
+	inFlight   bool
+	closed     bool
+	overflows  uint64
+	dropped    uint64
+	workerDone chan struct{}
+
+	errors       chan reportedError
+	reporterDone chan struct{}
+}
+
+type reportedError struct {
+	onError func(err error)
+	err     error
+}
+
+// NewAsyncOutput moves calls to inner.Append to a worker goroutine, so that slow outputs do not block the logging goroutine
+func NewAsyncOutput(inner Output, opts AsyncOutputOptions) *asyncOutput {
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = DEFAULT_ASYNC_QUEUE_SIZE
+	}
+
+	if opts.SampleRate <= 0 {
+		opts.SampleRate = 1
+	}
+
+	lock := &sync.Mutex{}
+	out := &asyncOutput{
+		inner:      inner,
+		policy:     opts.DropPolicy,
+		sample:     opts.SampleRate,
+		lock:       lock,
+		cond:       sync.NewCond(lock),
+		queue:      make([]*queuedRow, opts.QueueSize),
+		workerDone: make(chan struct{}),
+
+		errors:       make(chan reportedError, ASYNC_ERROR_QUEUE_SIZE),
+		reporterDone: make(chan struct{}),
+	}
+
+	go out.drain()
+	go out.reportErrors()
+
+	return out
+}
+
+func (out *asyncOutput) SetFilters(filters ...Filter) {
+	out.filters = and{filters}
+}
+
+func (out *asyncOutput) unwrap() Output {
+	return out.inner
+}
+
+func (out *asyncOutput) Append(onError func(err error), level string, message string, fields ...*Field) {
+	out.appendAt(onError, time.Now(), level, message, fields...)
+}
+
+func (out *asyncOutput) appendAt(onError func(err error), timestamp time.Time, level string, message string, fields ...*Field) {
+	if !out.levelThreshold.allows(level) || !out.filters.Allows(level, message, fields) {
+		return
+	}
+
+	if writeThrough := out.enqueue(&queuedRow{onError, timestamp, level, message, fields}); writeThrough {
+		appendAt(out.inner, onError, timestamp, level, message, fields...)
+	}
+}
+
+// returns true if the output was closed, meaning that nothing is draining the queue anymore
+func (out *asyncOutput) enqueue(row *queuedRow) (writeThrough bool) {
+	out.lock.Lock()
+	defer out.lock.Unlock()
+
+	for out.policy == DropPolicyBlock && out.count == len(out.queue) && !out.closed {
+		out.cond.Wait()
+	}
+
+	if out.closed {
+		return true
+	}
+
+	if out.count == len(out.queue) {
+		switch out.policy {
+		case DropPolicyNewest:
+			out.dropped++
+			return false
+		case DropPolicyOldest:
+			out.popOldest()
+			out.dropped++
+		case DropPolicySample:
+			out.overflows++
+			out.dropped++
+			if (out.overflows-1)%uint64(out.sample) != 0 {
+				return false
+			}
+			out.popOldest()
+		}
+	}
+
+	out.queue[(out.head+out.count)%len(out.queue)] = row
+	out.count++
+	out.cond.Broadcast()
+
+	return false
+}
+
+// assumes lock
+func (out *asyncOutput) popOldest() *queuedRow {
+	row := out.queue[out.head]
+	out.queue[out.head] = nil
+	out.head = (out.head + 1) % len(out.queue)
+	out.count--
+	return row
+}
+
+func (out *asyncOutput) drain() {
+	defer close(out.workerDone)
+
+	for {
+		out.lock.Lock()
+		for out.count == 0 && !out.closed {
+			out.cond.Wait()
+		}
+		if out.count == 0 && out.closed {
+			out.lock.Unlock()
+			return
+		}
+
+		row := out.popOldest()
+		dropped := out.dropped
+		out.dropped = 0
+		out.inFlight = true
+		out.cond.Broadcast()
+		out.lock.Unlock()
+
+		out.write(row, dropped)
+
+		out.lock.Lock()
+		out.inFlight = false
+		out.cond.Broadcast()
+		out.lock.Unlock()
+	}
+}
+
+// the worker has no caller to recover for it, so a panicking inner output is reported like basicLogger.appendTo does
+func (out *asyncOutput) write(row *queuedRow, dropped uint64) {
+	onError := func(err error) {
+		out.reportError(row.onError, err)
+	}
+
+	defer func() {
+		if recoveredError := recover(); recoveredError != nil {
+			onError(panicToError(recoveredError))
+		}
+	}()
+
+	if dropped > 0 {
+		out.inner.Append(onError, "metric", "Metric recorded", Uint64("async-output-dropped-rows", dropped))
+	}
+	appendAt(out.inner, onError, row.timestamp, row.level, row.message, row.fields...)
+}
+
+// errors are handed to a single reporter goroutine because the logger logs them back to this output, which would block the worker on its own queue.
+// if the reporter falls behind, further errors are discarded
+func (out *asyncOutput) reportError(onError func(err error), err error) {
+	select {
+	case out.errors <- reportedError{onError, err}:
+	default:
+	}
+}
+
+func (out *asyncOutput) reportErrors() {
+	defer close(out.reporterDone)
+
+	for e := range out.errors {
+		e.onError(e.err)
+	}
+}
+
+// Flush blocks until all rows queued so far were handed to the inner output, or ctx is done
+func (out *asyncOutput) Flush(ctx context.Context) error {
+	cancelled := false
+	drained := make(chan struct{})
+	go func() {
+		out.lock.Lock()
+		for (out.count > 0 || out.inFlight) && !cancelled {
+			out.cond.Wait()
+		}
+		out.lock.Unlock()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+		return nil
+	case <-ctx.Done():
+		// wake the waiter so it does not outlive this call
+		out.lock.Lock()
+		cancelled = true
+		out.cond.Broadcast()
+		out.lock.Unlock()
+		<-drained
+		return ctx.Err()
+	}
+}
+
+// Close drains the queue and stops the worker; rows appended after Close are written synchronously
+func (out *asyncOutput) Close() error {
+	out.lock.Lock()
+	if out.closed {
+		out.lock.Unlock()
+		return nil
+	}
+	out.closed = true
+	out.cond.Broadcast()
+	out.lock.Unlock()
+
+	<-out.workerDone
+	close(out.errors)
+	<-out.reporterDone
+
+	return nil
+}
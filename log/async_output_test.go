@@ -0,0 +1,225 @@
+// Copyright 2019 the orbs-network-go authors
+// This file is part of the orbs-network-go library in the Orbs project.
+//
+// This source code is licensed under the MIT license found in the LICENSE file in the root directory of this source tree.
+// The above notice should be included in all copies or substantial portions of the software.
+
+package log
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type blockingOutput struct {
+	lock     sync.Mutex
+	messages []string
+	fields   [][]*Field
+	entered  chan struct{}
+	release  chan struct{}
+}
+
+func newBlockingOutput() *blockingOutput {
+	return &blockingOutput{
+		entered: make(chan struct{}, 100),
+		release: make(chan struct{}),
+	}
+}
+
+func (o *blockingOutput) SetFilters(_ ...Filter) {
+}
+
+func (o *blockingOutput) Append(onError func(err error), level string, message string, fields ...*Field) {
+	o.lock.Lock()
+	o.messages = append(o.messages, message)
+	o.fields = append(o.fields, fields)
+	o.lock.Unlock()
+
+	o.entered <- struct{}{}
+	<-o.release
+}
+
+func (o *blockingOutput) recorded() ([]string, [][]*Field) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	return o.messages, o.fields
+}
+
+func fillBlockedAsyncOutput(t *testing.T, policy DropPolicy) *blockingOutput {
+	inner := newBlockingOutput()
+	output := NewAsyncOutput(inner, AsyncOutputOptions{QueueSize: 2, DropPolicy: policy})
+	defer output.Close()
+
+	output.Append(onErrorStub, "info", "1")
+	<-inner.entered // the worker is now stuck on the first row, so the queue fills up
+
+	for _, message := range []string{"2", "3", "4", "5"} {
+		output.Append(onErrorStub, "info", message)
+	}
+	close(inner.release)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	require.NoError(t, output.Flush(ctx))
+
+	return inner
+}
+
+func TestAsyncOutput_WritesRowsInOrder(t *testing.T) {
+	b := new(bytes.Buffer)
+	output := NewAsyncOutput(NewFormattingOutput(b, nopFormatter{}), AsyncOutputOptions{})
+	logger := GetLogger().WithOutput(output)
+
+	logger.Info("Ground control to Major Tom")
+	logger.Info("Commencing countdown")
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	require.NoError(t, output.Flush(ctx))
+
+	require.Equal(t, "Ground control to Major Tom\nCommencing countdown\n", b.String())
+}
+
+func TestAsyncOutput_DropNewest(t *testing.T) {
+	inner := fillBlockedAsyncOutput(t, DropPolicyNewest)
+
+	messages, fields := inner.recorded()
+	require.Equal(t, []string{"1", "Metric recorded", "2", "3"}, messages)
+	require.EqualValues(t, 2, fields[1][0].Uint)
+}
+
+func TestAsyncOutput_DropOldest(t *testing.T) {
+	inner := fillBlockedAsyncOutput(t, DropPolicyOldest)
+
+	messages, fields := inner.recorded()
+	require.Equal(t, []string{"1", "Metric recorded", "4", "5"}, messages)
+	require.EqualValues(t, 2, fields[1][0].Uint)
+}
+
+func TestAsyncOutput_SampleOnOverflow(t *testing.T) {
+	inner := newBlockingOutput()
+	output := NewAsyncOutput(inner, AsyncOutputOptions{QueueSize: 1, DropPolicy: DropPolicySample, SampleRate: 2})
+	defer output.Close()
+
+	output.Append(onErrorStub, "info", "1")
+	<-inner.entered
+
+	for _, message := range []string{"2", "3", "4", "5"} {
+		output.Append(onErrorStub, "info", message)
+	}
+	close(inner.release)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	require.NoError(t, output.Flush(ctx))
+
+	messages, fields := inner.recorded()
+	require.Equal(t, []string{"1", "Metric recorded", "5"}, messages)
+	require.EqualValues(t, 3, fields[1][0].Uint)
+}
+
+func TestAsyncOutput_Block(t *testing.T) {
+	inner := newBlockingOutput()
+	output := NewAsyncOutput(inner, AsyncOutputOptions{QueueSize: 1, DropPolicy: DropPolicyBlock})
+
+	output.Append(onErrorStub, "info", "1")
+	<-inner.entered
+	output.Append(onErrorStub, "info", "2")
+
+	appended := make(chan struct{})
+	go func() {
+		output.Append(onErrorStub, "info", "3")
+		close(appended)
+	}()
+
+	select {
+	case <-appended:
+		require.Fail(t, "Append did not block on a full queue")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(inner.release)
+	<-appended
+	require.NoError(t, output.Close())
+
+	messages, _ := inner.recorded()
+	require.Equal(t, []string{"1", "2", "3"}, messages)
+}
+
+func TestAsyncOutput_FlushRespectsContext(t *testing.T) {
+	inner := newBlockingOutput()
+	output := NewAsyncOutput(inner, AsyncOutputOptions{})
+	defer output.Close()
+	defer close(inner.release)
+
+	output.Append(onErrorStub, "info", "1")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	require.Equal(t, context.DeadlineExceeded, output.Flush(ctx))
+}
+
+func TestAsyncOutput_FlushDoesNotLeakWaiterWhenContextExpires(t *testing.T) {
+	inner := newBlockingOutput()
+	output := NewAsyncOutput(inner, AsyncOutputOptions{})
+	defer output.Close()
+	defer close(inner.release)
+
+	output.Append(onErrorStub, "info", "1")
+	<-inner.entered
+
+	before := runtime.NumGoroutine()
+	for i := 0; i < 10; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+		require.Equal(t, context.DeadlineExceeded, output.Flush(ctx))
+		cancel()
+	}
+	require.True(t, runtime.NumGoroutine() <= before, "Flush left goroutines behind")
+}
+
+func TestAsyncOutput_ReportsErrorsOfInnerOutputFromASingleGoroutine(t *testing.T) {
+	var reported int32
+	output := NewAsyncOutput(&erroneousOutput{err: fmt.Errorf("kaboom")}, AsyncOutputOptions{})
+
+	for i := 0; i < 10; i++ {
+		output.Append(func(err error) { atomic.AddInt32(&reported, 1) }, "info", "foo")
+	}
+	require.NoError(t, output.Close())
+
+	require.EqualValues(t, 10, atomic.LoadInt32(&reported))
+}
+
+func TestAsyncOutput_CloseDrainsQueueAndWritesThroughAfterwards(t *testing.T) {
+	b := new(bytes.Buffer)
+	output := NewAsyncOutput(NewFormattingOutput(b, nopFormatter{}), AsyncOutputOptions{})
+
+	output.Append(onErrorStub, "info", "foo")
+	require.NoError(t, output.Close())
+	require.Equal(t, "foo\n", b.String())
+
+	output.Append(onErrorStub, "info", "bar")
+	require.Equal(t, "foo\nbar\n", b.String())
+}
+
+func TestAsyncOutput_ReportsPanicsOfInnerOutput(t *testing.T) {
+	errors := make(chan error, 1)
+	output := NewAsyncOutput(&panickingOutput{errObj: "kaboom"}, AsyncOutputOptions{})
+	defer output.Close()
+
+	output.Append(func(err error) { errors <- err }, "info", "foo")
+
+	select {
+	case err := <-errors:
+		require.EqualError(t, err, "kaboom")
+	case <-time.After(time.Second):
+		require.Fail(t, "panic was not reported")
+	}
+}
@@ -16,6 +16,7 @@ type basicOutput struct {
 	formatter LogFormatter
 	writer    io.Writer
 	filters   and
+	levelThreshold
 }
 
 func (out *basicOutput) SetFilters(filters ...Filter) {
@@ -23,17 +24,25 @@ func (out *basicOutput) SetFilters(filters ...Filter) {
 }
 
 func (out *basicOutput) Append(onError func(err error), level string, message string, fields ...*Field) {
-	if !out.filters.Allows(level, message, fields) {
+	out.appendAt(onError, time.Now(), level, message, fields...)
+}
+
+func (out *basicOutput) appendAt(onError func(err error), timestamp time.Time, level string, message string, fields ...*Field) {
+	if !out.levelThreshold.allows(level) || !out.filters.Allows(level, message, fields) {
 		return
 	}
 
-	logLine := out.formatter.FormatRow(time.Now(), level, message, fields...)
+	logLine := out.formatter.FormatRow(timestamp, level, message, fields...)
 	_, err := fmt.Fprintln(out.writer, logLine)
 	if err != nil {
 		onError(err)
 	}
 }
 
-func NewFormattingOutput(writer io.Writer, formatter LogFormatter) Output {
+func (out *basicOutput) formatsAggregates() bool {
+	return formatsAggregates(out.formatter)
+}
+
+func NewFormattingOutput(writer io.Writer, formatter LogFormatter) LeveledOutput {
 	return &basicOutput{formatter: formatter, writer: writer}
 }
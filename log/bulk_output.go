@@ -8,111 +8,236 @@ package log
 
 import (
 	"bytes"
+	"context"
 	"fmt"
-	"github.com/pkg/errors"
 	"io"
-	"net/http"
 	"sync"
 	"time"
+
+	"github.com/pkg/errors"
 )
 
-type row struct {
-	level     string
-	timestamp time.Time
-	message   string
-	fields    []*Field
+const DEFAULT_BULK_FLUSH_TIMEOUT = 30 * time.Second
+
+// BulkOutput sends rows to its writer in bulks, see BulkOutputOptions for when a bulk is sent
+type BulkOutput interface {
+	LeveledOutput
+	// Flush sends the pending rows and waits until every bulk sent so far was written, or until the flush timeout elapses
+	Flush() error
+	// FlushContext is like Flush, but waits until ctx is done instead of the flush timeout
+	FlushContext(ctx context.Context) error
+	// Close stops the flush ticker and flushes
+	Close() error
 }
 
 type bulkOutput struct {
 	formatter LogFormatter
 	writer    io.Writer
 
-	bulkSize int
+	bulkSize      int
+	maxBytes      int
+	flushInterval time.Duration
+	flushTimeout  time.Duration
+
+	lock        *sync.Mutex
+	payload     *bytes.Buffer
+	count       int
+	lastOnError func(err error)
+	bulks       []*bulk       // sent but not yet written, in order
+	written     chan struct{} // closed once the write loop has written every bulk; nil when it is not running
+	writeErr    error
+	closed      chan struct{}
+	closeOnce   *sync.Once
+	tickerDone  chan struct{}
+	filters     and
+	levelThreshold
+}
 
-	lock    *sync.Mutex
-	logs    []*row
-	filters and
+type bulk struct {
+	payload []byte
+	onError func(err error)
+}
+
+type BulkOutputOptions struct {
+	// a bulk is sent once it has this many rows; zero or less sends every row on its own
+	BulkSize int
+	// if positive, a bulk is sent before its payload grows beyond this many bytes
+	MaxBytes int
+	// if positive, a background ticker sends whatever rows are pending at this interval
+	FlushInterval time.Duration
+	// how long Flush and Close wait for bulks to be written; defaults to DEFAULT_BULK_FLUSH_TIMEOUT
+	FlushTimeout time.Duration
 }
 
 func (out *bulkOutput) SetFilters(filters ...Filter) {
 	out.filters = and{filters}
 }
 
+func (out *bulkOutput) formatsAggregates() bool {
+	return formatsAggregates(out.formatter)
+}
+
 func (out *bulkOutput) Append(onError func(err error), level string, message string, fields ...*Field) {
-	if !out.filters.Allows(level, message, fields) {
+	out.appendAt(onError, time.Now(), level, message, fields...)
+}
+
+func (out *bulkOutput) appendAt(onError func(err error), timestamp time.Time, level string, message string, fields ...*Field) {
+	if !out.levelThreshold.allows(level) || !out.filters.Allows(level, message, fields) {
 		return
 	}
-	row := &row{level, time.Now(), message, fields}
+	line := out.formatter.FormatRow(timestamp, level, message, fields...)
 
 	out.lock.Lock()
-	out.logs = append(out.logs, row)
-	out.lock.Unlock()
+	defer out.lock.Unlock()
+
+	out.lastOnError = onError
 
-	out.flushIfNeeded(onError)
+	if out.maxBytes > 0 && out.count > 0 && out.payload.Len()+len(line)+1 > out.maxBytes {
+		out.send(onError)
+	}
+
+	out.payload.WriteString(line)
+	out.payload.WriteString("\n")
+	out.count++
+
+	if out.count >= out.bulkSize || (out.maxBytes > 0 && out.payload.Len() >= out.maxBytes) {
+		out.send(onError)
+	}
 }
 
-func (out *bulkOutput) flushIfNeeded(onError func(err error)) {
-	out.lock.Lock()
-	defer out.lock.Unlock()
+// hands the pending rows to the write loop, which writes bulks one at a time so they reach the writer in order.
+// assumes lock
+func (out *bulkOutput) send(onError func(err error)) {
+	if out.count == 0 {
+		return
+	}
+
+	out.bulks = append(out.bulks, &bulk{out.payload.Bytes(), onError})
+	out.payload = new(bytes.Buffer)
+	out.count = 0
 
-	if len(out.logs) >= out.bulkSize {
-		b := new(bytes.Buffer)
+	if out.written == nil {
+		out.written = make(chan struct{})
+		go out.writeBulks(out.written)
+	}
+}
 
-		for _, row := range out.logs {
-			b.Write([]byte(out.formatter.FormatRow(row.timestamp, row.level, row.message, row.fields...)))
-			b.Write([]byte("\n"))
+func (out *bulkOutput) writeBulks(written chan struct{}) {
+	for {
+		out.lock.Lock()
+		if len(out.bulks) == 0 {
+			out.written = nil
+			close(written)
+			out.lock.Unlock()
+			return
 		}
+		b := out.bulks[0]
+		out.bulks = out.bulks[1:]
+		out.lock.Unlock()
 
-		out.logs = nil
+		if _, err := out.writer.Write(b.payload); err != nil {
+			out.lock.Lock()
+			out.writeErr = err
+			out.lock.Unlock()
 
-		go func() {
-			if n, err := out.writer.Write(b.Bytes()); err != nil {
-				onError(err)
-				fmt.Println(fmt.Sprintf("%s failed to send logs via http, %d bytes lost: %s", time.Now().String(), n, err))
+			if b.onError != nil {
+				b.onError(err)
 			}
-		}()
+		}
 	}
 }
 
-func NewBulkOutput(writer io.Writer, formatter LogFormatter, bulkSize int) Output {
-	if bulkSize > 1000 {
-		panic(fmt.Sprintf("bulk size can't be greater than 1000, please refer to this issue for explanation: https://github.com/orbs-network/orbs-network-go/issues/501"))
-	}
+func (out *bulkOutput) Flush() error {
+	ctx, cancel := context.WithTimeout(context.Background(), out.flushTimeout)
+	defer cancel()
+
+	return out.FlushContext(ctx)
+}
 
-	return &bulkOutput{
-		formatter: formatter,
-		writer:    writer,
-		bulkSize:  bulkSize,
-		lock:      &sync.Mutex{},
+func (out *bulkOutput) FlushContext(ctx context.Context) error {
+	out.lock.Lock()
+	out.writeErr = nil
+	out.send(out.lastOnError)
+	written := out.written
+	out.lock.Unlock()
+
+	if written != nil {
+		select {
+		case <-written:
+		case <-ctx.Done():
+			return errors.Wrap(ctx.Err(), "bulks were not written before flush timed out")
+		}
 	}
+
+	out.lock.Lock()
+	defer out.lock.Unlock()
+
+	return out.writeErr
 }
 
-type httpWriter struct {
-	url        string
-	httpClient *http.Client
+func (out *bulkOutput) Close() error {
+	out.closeOnce.Do(func() {
+		close(out.closed)
+	})
+	<-out.tickerDone
+
+	return out.Flush()
 }
 
-func (w *httpWriter) Write(p []byte) (n int, err error) {
-	reader := bytes.NewReader(p)
-	size := reader.Len()
-	resp, err := w.httpClient.Post(w.url, "application/json", reader)
+func (out *bulkOutput) flushPeriodically() {
+	defer close(out.tickerDone)
 
-	if err != nil {
-		return 0, errors.Errorf("Failed to send logs: %s", err)
+	if out.flushInterval <= 0 {
+		return
 	}
 
-	defer resp.Body.Close()
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return 0, errors.Errorf("Failed to send logs: %d, %s", resp.StatusCode, err)
+	ticker := time.NewTicker(out.flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			out.lock.Lock()
+			out.send(out.lastOnError)
+			out.lock.Unlock()
+		case <-out.closed:
+			return
+		}
 	}
-
-	return size, err
 }
 
-func NewHttpWriter(url string) *httpWriter {
-	return NewHttpWriterWithTimeout(url, time.Second*60)
+func NewBulkOutput(writer io.Writer, formatter LogFormatter, bulkSize int) BulkOutput {
+	return NewBulkOutputWithOptions(writer, formatter, BulkOutputOptions{BulkSize: bulkSize})
 }
 
-func NewHttpWriterWithTimeout(url string, timeout time.Duration) *httpWriter {
-	return &httpWriter{url, &http.Client{Timeout: timeout}}
+func NewBulkOutputWithOptions(writer io.Writer, formatter LogFormatter, opts BulkOutputOptions) BulkOutput {
+	if opts.BulkSize > 1000 {
+		panic(fmt.Sprintf("bulk size can't be greater than 1000, please refer to this issue for explanation: https://github.com/orbs-network/orbs-network-go/issues/501"))
+	}
+
+	if opts.BulkSize <= 0 {
+		opts.BulkSize = 1
+	}
+
+	if opts.FlushTimeout <= 0 {
+		opts.FlushTimeout = DEFAULT_BULK_FLUSH_TIMEOUT
+	}
+
+	out := &bulkOutput{
+		formatter:     formatter,
+		writer:        writer,
+		bulkSize:      opts.BulkSize,
+		maxBytes:      opts.MaxBytes,
+		flushInterval: opts.FlushInterval,
+		flushTimeout:  opts.FlushTimeout,
+		lock:          &sync.Mutex{},
+		payload:       new(bytes.Buffer),
+		closed:        make(chan struct{}),
+		closeOnce:     &sync.Once{},
+		tickerDone:    make(chan struct{}),
+	}
+
+	go out.flushPeriodically()
+
+	return out
 }
@@ -44,6 +44,43 @@ func (w *testWriter) Write(p []byte) (n int, err error) {
 	return len(p), nil
 }
 
+type recordingWriter struct {
+	sync.Mutex
+	bulks   []string
+	written chan struct{}
+	err     error
+}
+
+func newRecordingWriter() *recordingWriter {
+	return &recordingWriter{written: make(chan struct{}, 100)}
+}
+
+func (w *recordingWriter) Write(p []byte) (n int, err error) {
+	w.Lock()
+	w.bulks = append(w.bulks, string(p))
+	w.Unlock()
+	w.written <- struct{}{}
+	if w.err != nil {
+		return 0, w.err
+	}
+	return len(p), nil
+}
+
+func (w *recordingWriter) recorded() []string {
+	w.Lock()
+	defer w.Unlock()
+	return w.bulks
+}
+
+type blockingWriter struct {
+	unblock chan struct{}
+}
+
+func (w *blockingWriter) Write(p []byte) (n int, err error) {
+	<-w.unblock
+	return len(p), nil
+}
+
 func (h *httpOutputHarness) start(t *testing.T) {
 	ch := make(chan struct{})
 	go func() {
@@ -68,7 +105,8 @@ func (h *httpOutputHarness) start(t *testing.T) {
 
 func (h *httpOutputHarness) stop(t *testing.T) {
 	if h.server != nil {
-		ctx, _ := context.WithTimeout(context.Background(), 2*time.Millisecond)
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Millisecond)
+		defer cancel()
 		if err := h.server.Shutdown(ctx); err != nil {
 			t.Error("failed to stop http server gracefully", err)
 		}
@@ -154,6 +192,148 @@ func TestBulkOutput_SetFilters(t *testing.T) {
 	<-batch
 }
 
+func TestBulkOutput_SetMinLevel(t *testing.T) {
+	batch := make(chan struct{}, 1)
+	timeout := make(chan struct{})
+	go func() {
+		time.Sleep(1 * time.Second)
+		close(timeout)
+	}()
+
+	output := NewBulkOutput(
+		&testWriter{batch},
+		nopFormatter{},
+		2)
+	output.SetMinLevel(WarnLevel)
+	logger := GetLogger().WithOutput(output)
+
+	logger.Warn("Ground control to Major Tom")
+	logger.Info("Commencing countdown")
+	select {
+	case <-batch:
+		require.Fail(t, "Row below threshold was not filtered")
+	case <-timeout:
+	}
+	logger.Error("Engines on")
+	<-batch
+}
+
+func TestBulkOutput_FlushesWhenBulkSizeReached(t *testing.T) {
+	w := newRecordingWriter()
+	output := NewBulkOutput(w, nopFormatter{}, 2)
+
+	output.Append(onErrorStub, "info", "foo")
+	output.Append(onErrorStub, "info", "bar")
+	output.Append(onErrorStub, "info", "baz")
+	<-w.written
+
+	require.Equal(t, []string{"foo\nbar\n"}, w.recorded())
+}
+
+func TestBulkOutput_FlushesBeforeExceedingMaxBytes(t *testing.T) {
+	w := newRecordingWriter()
+	output := NewBulkOutputWithOptions(w, nopFormatter{}, BulkOutputOptions{BulkSize: 1000, MaxBytes: 10})
+
+	output.Append(onErrorStub, "info", "foo")
+	output.Append(onErrorStub, "info", "bar")
+	output.Append(onErrorStub, "info", "bazqux")
+	require.NoError(t, output.Flush())
+
+	require.Equal(t, []string{"foo\nbar\n", "bazqux\n"}, w.recorded())
+}
+
+func TestBulkOutput_FlushesWhenMaxBytesReached(t *testing.T) {
+	w := newRecordingWriter()
+	output := NewBulkOutputWithOptions(w, nopFormatter{}, BulkOutputOptions{BulkSize: 1000, MaxBytes: 8})
+
+	output.Append(onErrorStub, "info", "foo")
+	output.Append(onErrorStub, "info", "bar")
+	<-w.written
+
+	require.Equal(t, []string{"foo\nbar\n"}, w.recorded())
+}
+
+func TestBulkOutput_FlushesWhenIntervalElapsed(t *testing.T) {
+	w := newRecordingWriter()
+	output := NewBulkOutputWithOptions(w, nopFormatter{}, BulkOutputOptions{BulkSize: 1000, FlushInterval: 20 * time.Millisecond})
+	defer output.Close()
+
+	output.Append(onErrorStub, "info", "foo")
+
+	select {
+	case <-w.written:
+	case <-time.After(time.Second):
+		require.Fail(t, "Timed out waiting for batch")
+	}
+	require.Equal(t, []string{"foo\n"}, w.recorded())
+}
+
+func TestBulkOutput_WritesBulksInOrder(t *testing.T) {
+	w := newRecordingWriter()
+	output := NewBulkOutput(w, nopFormatter{}, 1)
+
+	var expected []string
+	for i := 0; i < 50; i++ {
+		output.Append(onErrorStub, "info", fmt.Sprintf("%d", i))
+		expected = append(expected, fmt.Sprintf("%d\n", i))
+	}
+	require.NoError(t, output.Flush())
+
+	require.Equal(t, expected, w.recorded())
+}
+
+func TestBulkOutput_FlushReportsWriteErrors(t *testing.T) {
+	w := newRecordingWriter()
+	w.err = fmt.Errorf("kaboom")
+	var reported error
+	output := NewBulkOutput(w, nopFormatter{}, 1000)
+
+	output.Append(func(err error) { reported = err }, "info", "foo")
+
+	require.EqualError(t, output.Flush(), "kaboom")
+	require.EqualError(t, reported, "kaboom")
+}
+
+func TestBulkOutput_Close(t *testing.T) {
+	w := newRecordingWriter()
+	output := NewBulkOutputWithOptions(w, nopFormatter{}, BulkOutputOptions{BulkSize: 1000, FlushInterval: time.Hour})
+
+	output.Append(onErrorStub, "info", "foo")
+	require.NoError(t, output.Close())
+	require.Equal(t, []string{"foo\n"}, w.recorded())
+
+	// the ticker is already stopped, closing again only flushes
+	output.Append(onErrorStub, "info", "bar")
+	require.NoError(t, output.Close())
+	require.Equal(t, []string{"foo\n", "bar\n"}, w.recorded())
+}
+
+func TestBulkOutput_ZeroBulkSizeSendsEveryRow(t *testing.T) {
+	w := newRecordingWriter()
+	output := NewBulkOutput(w, nopFormatter{}, 0)
+
+	output.Append(onErrorStub, "info", "foo")
+	<-w.written
+	output.Append(onErrorStub, "info", "bar")
+	<-w.written
+
+	require.Equal(t, []string{"foo\n", "bar\n"}, w.recorded())
+}
+
+func TestBulkOutput_FlushGivesUpOnAStuckWriter(t *testing.T) {
+	unblock := make(chan struct{})
+	defer close(unblock)
+	w := &blockingWriter{unblock: unblock}
+	output := NewBulkOutputWithOptions(w, nopFormatter{}, BulkOutputOptions{BulkSize: 1000, FlushTimeout: 20 * time.Millisecond})
+
+	output.Append(onErrorStub, "info", "foo")
+	require.Error(t, output.Flush())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	require.Error(t, output.FlushContext(ctx))
+}
+
 func TestBulkOutput_Append_Http(t *testing.T) {
 	wg := sync.WaitGroup{}
 	wg.Add(1)
@@ -0,0 +1,65 @@
+// Copyright 2019 the orbs-network-go authors
+// This file is part of the orbs-network-go library in the Orbs project.
+//
+// This source code is licensed under the MIT license found in the LICENSE file in the root directory of this source tree.
+// The above notice should be included in all copies or substantial portions of the software.
+
+package log
+
+import (
+	"bytes"
+	"compress/gzip"
+)
+
+// asks the compressor for its default level, as 0 is a valid level (gzip.NoCompression for gzip)
+const DEFAULT_COMPRESSION_LEVEL = -1
+
+// Compressor encodes payloads for httpWriter. gzip is built in; other encodings (such as zstd) can be plugged in
+// by implementing this interface on top of the library of your choice
+type Compressor interface {
+	// the value for the Content-Encoding header
+	ContentEncoding() string
+	Compress(p []byte, level int) ([]byte, error)
+}
+
+type CompressionOptions struct {
+	// defaults to gzip
+	Compressor Compressor
+	// passed to the compressor as is; DEFAULT_COMPRESSION_LEVEL means the compressor's default level
+	Level int
+	// bulks smaller than this are sent uncompressed
+	MinSize int
+}
+
+type gzipCompressor struct {
+}
+
+func GzipCompressor() Compressor {
+	return &gzipCompressor{}
+}
+
+func (c *gzipCompressor) ContentEncoding() string {
+	return "gzip"
+}
+
+func (c *gzipCompressor) Compress(p []byte, level int) ([]byte, error) {
+	if level == DEFAULT_COMPRESSION_LEVEL {
+		level = gzip.DefaultCompression
+	}
+
+	b := new(bytes.Buffer)
+	w, err := gzip.NewWriterLevel(b, level)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := w.Write(p); err != nil {
+		return nil, err
+	}
+
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	return b.Bytes(), nil
+}
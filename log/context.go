@@ -0,0 +1,75 @@
+// Copyright 2019 the orbs-network-go authors
+// This file is part of the orbs-network-go library in the Orbs project.
+//
+// This source code is licensed under the MIT license found in the LICENSE file in the root directory of this source tree.
+// The above notice should be included in all copies or substantial portions of the software.
+
+package log
+
+import (
+	"context"
+	"time"
+)
+
+type contextKey int
+
+const (
+	loggerContextKey contextKey = iota
+	tagsContextKey
+)
+
+// NewContext returns a copy of ctx that carries logger, to be retrieved deeper down the call stack with FromContext
+func NewContext(ctx context.Context, logger Logger) context.Context {
+	return context.WithValue(ctx, loggerContextKey, logger)
+}
+
+// FromContext returns the logger stored on ctx by NewContext, or a new default logger if there is none
+func FromContext(ctx context.Context) Logger {
+	if ctx != nil {
+		if logger, ok := ctx.Value(loggerContextKey).(Logger); ok {
+			return logger
+		}
+	}
+
+	return GetLogger()
+}
+
+// ContextWithTags returns a copy of ctx that carries tags in addition to the ones already on ctx.
+// the *Ctx logger methods add them to the row after the logger's own tags
+func ContextWithTags(ctx context.Context, tags ...*Field) context.Context {
+	existing := TagsFromContext(ctx)
+	merged := make([]*Field, 0, len(existing)+len(tags))
+	merged = append(append(merged, existing...), tags...)
+
+	return context.WithValue(ctx, tagsContextKey, merged)
+}
+
+func TagsFromContext(ctx context.Context) []*Field {
+	if ctx == nil {
+		return nil
+	}
+
+	tags, _ := ctx.Value(tagsContextKey).([]*Field)
+	return tags
+}
+
+// ContextDeadlineFields describes the deadline and cancellation state of ctx, for example:
+//
+//	logger.InfoCtx(ctx, "waiting for block", log.ContextDeadlineFields(ctx)...)
+//
+// the cancellation cause is a string field rather than an Error field so that OnlyErrors does not pick it up
+func ContextDeadlineFields(ctx context.Context) []*Field {
+	var fields []*Field
+
+	if deadline, ok := ctx.Deadline(); ok {
+		fields = append(fields,
+			Timestamp("ctx-deadline", deadline),
+			String("ctx-time-left", time.Until(deadline).String()))
+	}
+
+	if err := ctx.Err(); err != nil {
+		fields = append(fields, String("ctx-error", err.Error()))
+	}
+
+	return fields
+}
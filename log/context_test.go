@@ -0,0 +1,67 @@
+// Copyright 2019 the orbs-network-go authors
+// This file is part of the orbs-network-go library in the Orbs project.
+//
+// This source code is licensed under the MIT license found in the LICENSE file in the root directory of this source tree.
+// The above notice should be included in all copies or substantial portions of the software.
+
+package log
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFromContext_ReturnsLoggerStoredByNewContext(t *testing.T) {
+	logger := GetLogger(Service("public-api"))
+	ctx := NewContext(context.Background(), logger)
+
+	require.Equal(t, logger, FromContext(ctx))
+}
+
+func TestFromContext_ReturnsDefaultLoggerWhenNoneStored(t *testing.T) {
+	require.NotNil(t, FromContext(context.Background()))
+}
+
+func TestContextWithTags_AddsToTagsAlreadyOnContext(t *testing.T) {
+	parent := ContextWithTags(context.Background(), String("request-id", "1"))
+	child := ContextWithTags(parent, String("node", "a"))
+
+	require.Equal(t, []*Field{String("request-id", "1")}, TagsFromContext(parent))
+	require.Equal(t, []*Field{String("request-id", "1"), String("node", "a")}, TagsFromContext(child))
+}
+
+func TestInfoCtx_MergesTagsFromContext(t *testing.T) {
+	b := new(bytes.Buffer)
+	logger := GetLogger(Service("public-api")).WithOutput(NewFormattingOutput(b, NewJsonFormatter()))
+	ctx := ContextWithTags(NewContext(context.Background(), logger), String("request-id", "abc"))
+
+	FromContext(ctx).InfoCtx(ctx, "Service initialized", Int("vcid", 42))
+
+	jsonMap := parseOutput(b.String())
+	require.Equal(t, "info", jsonMap["level"])
+	require.Equal(t, "public-api", jsonMap["service"])
+	require.Equal(t, "abc", jsonMap["request-id"])
+	require.Equal(t, 42.0, jsonMap["vcid"])
+	require.Equal(t, "log.TestInfoCtx_MergesTagsFromContext", jsonMap["function"])
+}
+
+func TestContextDeadlineFields(t *testing.T) {
+	require.Empty(t, ContextDeadlineFields(context.Background()))
+
+	deadline := time.Now().Add(time.Hour)
+	ctx, cancel := context.WithDeadline(context.Background(), deadline)
+	fields := ContextDeadlineFields(ctx)
+	require.Len(t, fields, 2)
+	require.Equal(t, "ctx-deadline", fields[0].Key)
+	require.Equal(t, deadline.UnixNano(), fields[0].Int)
+	require.Equal(t, "ctx-time-left", fields[1].Key)
+
+	cancel()
+	fields = ContextDeadlineFields(ctx)
+	require.Len(t, fields, 3)
+	require.Equal(t, String("ctx-error", context.Canceled.Error()), fields[2])
+}
@@ -0,0 +1,217 @@
+// Copyright 2019 the orbs-network-go authors
+// This file is part of the orbs-network-go library in the Orbs project.
+//
+// This source code is licensed under the MIT license found in the LICENSE file in the root directory of this source tree.
+// The above notice should be included in all copies or substantial portions of the software.
+
+package log
+
+import (
+	"bytes"
+	"sync"
+	"time"
+)
+
+const DEFAULT_DEDUPLICATION_WINDOW = 10 * time.Second
+
+type DeduplicationOptions struct {
+	// Window is how long repetitions of a row are held back before they are reported; defaults to DEFAULT_DEDUPLICATION_WINDOW
+	Window time.Duration
+	// IgnoreCallSite makes rows logged from different places count as repetitions if everything else is the same
+	IgnoreCallSite bool
+}
+
+type dedupOutput struct {
+	inner          Output
+	window         time.Duration
+	ignoreCallSite bool
+	filters        and
+	levelThreshold
+
+	lock       sync.Mutex
+	last       *queuedRow // the last row passed on to inner
+	firstSeen  time.Time
+	repeated   int
+	repeat     *queuedRow // the last repetition held back
+	generation uint64     // tells timers of past windows that they are stale
+	timer      *time.Timer
+}
+
+// NewDeduplicatingOutput passes rows on to inner, except for rows that are identical to the one before them (same level, message and fields),
+// which are held back and counted. when a different row arrives or the window that started with the first of them closes,
+// the last repetition is passed on with repeated (how many rows were held back), first_seen and last_seen fields
+func NewDeduplicatingOutput(inner Output, opts DeduplicationOptions) *dedupOutput {
+	if opts.Window <= 0 {
+		opts.Window = DEFAULT_DEDUPLICATION_WINDOW
+	}
+
+	return &dedupOutput{
+		inner:          inner,
+		window:         opts.Window,
+		ignoreCallSite: opts.IgnoreCallSite,
+	}
+}
+
+func (out *dedupOutput) SetFilters(filters ...Filter) {
+	out.filters = and{filters}
+}
+
+func (out *dedupOutput) unwrap() Output {
+	return out.inner
+}
+
+func (out *dedupOutput) Append(onError func(err error), level string, message string, fields ...*Field) {
+	out.appendAt(onError, time.Now(), level, message, fields...)
+}
+
+func (out *dedupOutput) appendAt(onError func(err error), timestamp time.Time, level string, message string, fields ...*Field) {
+	if !out.levelThreshold.allows(level) || !out.filters.Allows(level, message, fields) {
+		return
+	}
+
+	// rows are passed on once the lock is released, as inner may report errors by logging to this output
+	for _, row := range out.add(&queuedRow{onError, timestamp, level, message, fields}) {
+		appendAt(out.inner, row.onError, row.timestamp, row.level, row.message, row.fields...)
+	}
+}
+
+func (out *dedupOutput) add(row *queuedRow) (rows []*queuedRow) {
+	out.lock.Lock()
+	defer out.lock.Unlock()
+
+	if out.last != nil && out.isRepetition(row) {
+		if out.repeated == 0 {
+			out.startWindow()
+		}
+		out.repeated++
+		out.repeat = row
+		return nil
+	}
+
+	if summary := out.summarize(); summary != nil {
+		rows = append(rows, summary)
+	}
+	out.last = row
+	out.firstSeen = row.timestamp
+
+	return append(rows, row)
+}
+
+// assumes lock
+func (out *dedupOutput) isRepetition(row *queuedRow) bool {
+	return row.level == out.last.level && row.message == out.last.message &&
+		equalFields(row.fields, out.last.fields, out.ignoreCallSite)
+}
+
+// assumes lock
+func (out *dedupOutput) startWindow() {
+	generation := out.generation
+	out.timer = time.AfterFunc(out.window, func() {
+		out.closeWindow(generation)
+	})
+}
+
+func (out *dedupOutput) closeWindow(generation uint64) {
+	out.lock.Lock()
+	var summary *queuedRow
+	if generation == out.generation {
+		if summary = out.summarize(); summary != nil {
+			out.last = nil // so that the next repetition starts a new window by being passed on
+		}
+	}
+	out.lock.Unlock()
+
+	if summary != nil {
+		appendAt(out.inner, summary.onError, summary.timestamp, summary.level, summary.message, summary.fields...)
+	}
+}
+
+// assumes lock; returns the row that reports the repetitions held back, if there were any
+func (out *dedupOutput) summarize() *queuedRow {
+	if out.repeated == 0 {
+		return nil
+	}
+
+	out.timer.Stop()
+	out.generation++
+
+	repeat := out.repeat
+	fields := make([]*Field, 0, len(repeat.fields)+3)
+	fields = append(fields, repeat.fields...)
+	fields = append(fields,
+		Int("repeated", out.repeated),
+		Timestamp("first_seen", out.firstSeen),
+		Timestamp("last_seen", repeat.timestamp))
+
+	out.repeated = 0
+	out.repeat = nil
+
+	return &queuedRow{repeat.onError, repeat.timestamp, repeat.level, repeat.message, fields}
+}
+
+// Flush passes on the repetitions held back so far without waiting for their window to close
+func (out *dedupOutput) Flush() {
+	out.lock.Lock()
+	generation := out.generation
+	out.lock.Unlock()
+
+	out.closeWindow(generation)
+}
+
+func equalFields(a []*Field, b []*Field, ignoreCallSite bool) bool {
+	if ignoreCallSite {
+		a = withoutCallSite(a)
+		b = withoutCallSite(b)
+	}
+
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if !equalField(a[i], b[i], ignoreCallSite) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// unlike Field.Equal, compares aggregates, string arrays and errors by their contents
+func equalField(a *Field, b *Field, ignoreCallSite bool) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+
+	if a.Key != b.Key || a.Type != b.Type {
+		return false
+	}
+
+	switch a.Type {
+	case AggregateType:
+		return equalFields(a.Nested.NestedFields(), b.Nested.NestedFields(), ignoreCallSite)
+	case StringArrayType:
+		if len(a.StringArray) != len(b.StringArray) {
+			return false
+		}
+		for i := range a.StringArray {
+			if a.StringArray[i] != b.StringArray[i] {
+				return false
+			}
+		}
+		return true
+	case BytesType:
+		return bytes.Equal(a.Bytes, b.Bytes)
+	case ErrorType:
+		return logfmtValue(a) == logfmtValue(b)
+	default:
+		return a.Value() == b.Value()
+	}
+}
+
+func withoutCallSite(fields []*Field) []*Field {
+	_, fields = extractParamByConditionAndRemove(fields, func(f *Field) bool {
+		return f != nil && (f.Type == FunctionType || f.Type == SourceType)
+	})
+	return fields
+}
@@ -0,0 +1,113 @@
+// Copyright 2019 the orbs-network-go authors
+// This file is part of the orbs-network-go library in the Orbs project.
+//
+// This source code is licensed under the MIT license found in the LICENSE file in the root directory of this source tree.
+// The above notice should be included in all copies or substantial portions of the software.
+
+package log
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type syncBuffer struct {
+	lock sync.Mutex
+	b    bytes.Buffer
+}
+
+func (s *syncBuffer) Write(p []byte) (int, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.b.Write(p)
+}
+
+func (s *syncBuffer) lines() []map[string]interface{} {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	var rows []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(s.b.String()), "\n") {
+		if line != "" {
+			rows = append(rows, parseOutput(line))
+		}
+	}
+	return rows
+}
+
+func TestDeduplicatingOutput_CollapsesConsecutiveRepetitions(t *testing.T) {
+	b := &syncBuffer{}
+	output := NewDeduplicatingOutput(NewFormattingOutput(b, NewJsonFormatter()), DeduplicationOptions{Window: time.Hour, IgnoreCallSite: true})
+	logger := GetLogger().WithOutput(output)
+
+	for i := 0; i < 4; i++ {
+		logger.Error("reconnecting", Error(errors.New("connection refused")), Aggregate("peer", String("address", "a")))
+	}
+	logger.Info("connected")
+
+	rows := b.lines()
+	require.Len(t, rows, 3)
+	require.Equal(t, "reconnecting", rows[0]["message"])
+	require.NotContains(t, rows[0], "repeated")
+
+	require.Equal(t, "reconnecting", rows[1]["message"])
+	require.EqualValues(t, 3, rows[1]["repeated"])
+	require.NotEmpty(t, rows[1]["first_seen"])
+	require.NotEmpty(t, rows[1]["last_seen"])
+
+	require.Equal(t, "connected", rows[2]["message"])
+}
+
+func TestDeduplicatingOutput_ComparesFields(t *testing.T) {
+	b := &syncBuffer{}
+	logger := GetLogger().WithOutput(NewDeduplicatingOutput(NewFormattingOutput(b, NewJsonFormatter()), DeduplicationOptions{Window: time.Hour}))
+
+	logger.Info("foo", Int("attempt", 1))
+	logger.Info("foo", Int("attempt", 2))
+	logger.Warn("foo", Int("attempt", 2))
+	for i := 0; i < 2; i++ {
+		logger.Info("foo", Int("attempt", 2)) // logged from the same place, so function and source are the same
+	}
+
+	require.Len(t, b.lines(), 4, "rows with different fields or levels should not be collapsed")
+}
+
+func TestDeduplicatingOutput_ReportsWhenTheWindowCloses(t *testing.T) {
+	b := &syncBuffer{}
+	output := NewDeduplicatingOutput(NewFormattingOutput(b, NewJsonFormatter()), DeduplicationOptions{Window: 50 * time.Millisecond, IgnoreCallSite: true})
+	logger := GetLogger().WithOutput(output)
+
+	for i := 0; i < 3; i++ {
+		logger.Info("foo")
+	}
+
+	for start := time.Now(); len(b.lines()) < 2 && time.Since(start) < time.Second; {
+		time.Sleep(10 * time.Millisecond)
+	}
+	require.Len(t, b.lines(), 2)
+	require.EqualValues(t, 2, b.lines()[1]["repeated"])
+
+	logger.Info("foo")
+	output.Flush()
+	require.Len(t, b.lines(), 3, "a row after the window closed should be passed on")
+}
+
+func TestDeduplicatingOutput_FlushReportsHeldRows(t *testing.T) {
+	b := &syncBuffer{}
+	output := NewDeduplicatingOutput(NewFormattingOutput(b, NewJsonFormatter()), DeduplicationOptions{Window: time.Hour, IgnoreCallSite: true})
+	logger := GetLogger().WithOutput(output)
+
+	logger.Info("foo")
+	logger.Info("foo")
+	output.Flush()
+
+	rows := b.lines()
+	require.Len(t, rows, 2)
+	require.EqualValues(t, 1, rows[1]["repeated"])
+}
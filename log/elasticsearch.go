@@ -0,0 +1,186 @@
+// Copyright 2019 the orbs-network-go authors
+// This file is part of the orbs-network-go library in the Orbs project.
+//
+// This source code is licensed under the MIT license found in the LICENSE file in the root directory of this source tree.
+// The above notice should be included in all copies or substantial portions of the software.
+
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const ELASTICSEARCH_TIMESTAMP_COLUMN = "@timestamp"
+
+type elasticsearchFormatter struct {
+	indexTemplate string
+	source        LogFormatter
+}
+
+// NewElasticsearchFormatter formats each row as a _bulk index action followed by the row as its source document.
+// parts of indexTemplate in braces are time layouts applied to the row's UTC timestamp, so "logs-{2006.01.02}" indexes into daily indices.
+// meant to be used with NewBulkOutput and NewElasticsearchWriter
+func NewElasticsearchFormatter(indexTemplate string) *elasticsearchFormatter {
+	return &elasticsearchFormatter{
+		indexTemplate: indexTemplate,
+		source:        NewJsonFormatter().WithTimestampColumn(ELASTICSEARCH_TIMESTAMP_COLUMN),
+	}
+}
+
+// WithSourceFormatter replaces the formatter of source documents, which must produce single line JSON objects
+func (f *elasticsearchFormatter) WithSourceFormatter(source LogFormatter) *elasticsearchFormatter {
+	f.source = source
+	return f
+}
+
+func (f *elasticsearchFormatter) FormatRow(timestamp time.Time, level string, message string, params ...*Field) (formattedRow string) {
+	action, _ := json.Marshal(map[string]map[string]string{"index": {"_index": f.indexName(timestamp)}})
+
+	return string(action) + "\n" + f.source.FormatRow(timestamp, level, message, params...)
+}
+
+func (f *elasticsearchFormatter) formatsAggregates() bool {
+	return formatsAggregates(f.source)
+}
+
+func (f *elasticsearchFormatter) indexName(timestamp time.Time) string {
+	if !strings.Contains(f.indexTemplate, "{") {
+		return f.indexTemplate
+	}
+
+	var sb strings.Builder
+	template := f.indexTemplate
+	for {
+		start := strings.Index(template, "{")
+		end := strings.Index(template, "}")
+		if start < 0 || end < start {
+			sb.WriteString(template)
+			return sb.String()
+		}
+
+		sb.WriteString(template[:start])
+		sb.WriteString(timestamp.UTC().Format(template[start+1 : end]))
+		template = template[end+1:]
+	}
+}
+
+type elasticsearchWriter struct {
+	http *httpWriter
+}
+
+// NewElasticsearchWriter writes bulks of rows formatted by NewElasticsearchFormatter to the _bulk endpoint that writer posts to,
+// for example NewHttpWriter("https://es:9200/_bulk").WithBasicAuth(user, password).
+// items that the response reports as rejected with 429 or 5xx are sent again by themselves, following the retry policy of writer;
+// items rejected for other reasons (such as mapping errors) are reported and dropped.
+// spooling configured on writer is not used
+func NewElasticsearchWriter(writer *httpWriter) *elasticsearchWriter {
+	return &elasticsearchWriter{http: writer.WithContentType("application/x-ndjson")}
+}
+
+type elasticsearchBulkResponse struct {
+	Errors bool                                     `json:"errors"`
+	Items  []map[string]elasticsearchBulkItemResult `json:"items"`
+}
+
+type elasticsearchBulkItemResult struct {
+	Status int             `json:"status"`
+	Error  json.RawMessage `json:"error"`
+}
+
+func (w *elasticsearchWriter) Write(p []byte) (n int, err error) {
+	items, err := splitBulkItems(p)
+	if err != nil {
+		return 0, err
+	}
+
+	attempts := w.http.retries.MaxAttempts
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	var rejected []string
+	for attempt := 0; len(items) > 0; attempt++ {
+		if attempt > 0 {
+			time.Sleep(w.http.backoff(attempt, 0))
+		}
+
+		response, err := w.http.sendWithRetries(joinBulkItems(items))
+		if err != nil {
+			return 0, err
+		}
+
+		retry, retryReasons, failed, err := failedBulkItems(items, response)
+		if err != nil {
+			return 0, err
+		}
+		rejected = append(rejected, failed...)
+
+		if attempt+1 >= attempts {
+			rejected = append(rejected, retryReasons...)
+			break
+		}
+		items = retry
+	}
+
+	if len(rejected) > 0 {
+		return len(p), errors.Errorf("Elasticsearch rejected %d rows, first reason: %s", len(rejected), rejected[0])
+	}
+
+	return len(p), nil
+}
+
+// each item is an action line and a source line
+func splitBulkItems(p []byte) ([][]byte, error) {
+	lines := bytes.Split(bytes.TrimRight(p, "\n"), []byte("\n"))
+	if len(lines)%2 != 0 {
+		return nil, errors.Errorf("bulk payload has an odd number of lines, was it formatted by NewElasticsearchFormatter?")
+	}
+
+	var items [][]byte
+	for i := 0; i < len(lines); i += 2 {
+		item := make([]byte, 0, len(lines[i])+len(lines[i+1])+2)
+		item = append(append(append(append(item, lines[i]...), '\n'), lines[i+1]...), '\n')
+		items = append(items, item)
+	}
+
+	return items, nil
+}
+
+func joinBulkItems(items [][]byte) []byte {
+	return bytes.Join(items, nil)
+}
+
+// returns the items to send again with the reasons they failed, and the reasons of the items that will never be accepted
+func failedBulkItems(items [][]byte, response []byte) (retry [][]byte, retryReasons []string, rejected []string, err error) {
+	var parsed elasticsearchBulkResponse
+	if err := json.Unmarshal(response, &parsed); err != nil {
+		return nil, nil, nil, errors.Errorf("failed to parse bulk response: %s", err)
+	}
+
+	if !parsed.Errors {
+		return nil, nil, nil, nil
+	}
+
+	if len(parsed.Items) != len(items) {
+		return nil, nil, nil, errors.Errorf("bulk response has %d items for %d rows", len(parsed.Items), len(items))
+	}
+
+	for i, item := range parsed.Items {
+		for _, result := range item { // a single action per item, keyed by its type
+			switch {
+			case result.Status == 429 || result.Status >= 500:
+				retry = append(retry, items[i])
+				retryReasons = append(retryReasons, string(result.Error))
+			case result.Status < 200 || result.Status >= 300:
+				rejected = append(rejected, string(result.Error))
+			}
+		}
+	}
+
+	return retry, retryReasons, rejected, nil
+}
@@ -0,0 +1,172 @@
+// Copyright 2019 the orbs-network-go authors
+// This file is part of the orbs-network-go library in the Orbs project.
+//
+// This source code is licensed under the MIT license found in the LICENSE file in the root directory of this source tree.
+// The above notice should be included in all copies or substantial portions of the software.
+
+package log
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestElasticsearchFormatter_IndexActionAndSource(t *testing.T) {
+	tm, err := time.Parse(TIMESTAMP_FORMAT, "2026-10-16T23:59:59Z")
+	require.NoError(t, err)
+
+	row := NewElasticsearchFormatter("logs-{2006.01.02}").FormatRow(tm, "info", "foo", String("node", "node1"))
+
+	lines := strings.Split(row, "\n")
+	require.Len(t, lines, 2)
+	require.Equal(t, `{"index":{"_index":"logs-2026.10.16"}}`, lines[0])
+
+	source := parseOutput(lines[1])
+	require.Equal(t, "foo", source["message"])
+	require.Equal(t, "node1", source["node"])
+	require.Equal(t, "2026-10-16T23:59:59Z", source["@timestamp"])
+}
+
+func TestElasticsearchFormatter_IndexTemplates(t *testing.T) {
+	tm := time.Date(2026, 10, 16, 1, 2, 3, 0, time.UTC)
+
+	for template, index := range map[string]string{
+		"logs":                     "logs",
+		"logs-{2006.01}":           "logs-2026.10",
+		"{2006}-logs-{01}-{02}":    "2026-logs-10-16",
+		"logs-{2006.01.02}-{15}h":  "logs-2026.10.16-01h",
+		"unterminated-{2006.01.02": "unterminated-{2006.01.02",
+	} {
+		require.Equal(t, index, NewElasticsearchFormatter(template).indexName(tm), template)
+	}
+}
+
+type bulkRequest struct {
+	contentType string
+	messages    []string
+}
+
+// responds to every request with the statuses it pops from responses, one per item, and with 201s once they run out
+func newElasticsearchServer(responses ...[]int) (*httptest.Server, func() []bulkRequest) {
+	var lock sync.Mutex
+	var requests []bulkRequest
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer r.Body.Close()
+
+		request := bulkRequest{contentType: r.Header.Get("Content-Type")}
+		scanner := bufio.NewScanner(r.Body)
+		for i := 0; scanner.Scan(); i++ {
+			if i%2 == 1 {
+				request.messages = append(request.messages, parseOutput(scanner.Text())["message"].(string))
+			}
+		}
+
+		lock.Lock()
+		var statuses []int
+		if len(responses) > len(requests) {
+			statuses = responses[len(requests)]
+		}
+		requests = append(requests, request)
+		lock.Unlock()
+
+		errors := false
+		var items []string
+		for i := range request.messages {
+			status := 201
+			if i < len(statuses) {
+				status = statuses[i]
+			}
+			if status >= 300 {
+				errors = true
+				items = append(items, fmt.Sprintf(`{"index":{"status":%d,"error":{"type":"status_%d"}}}`, status, status))
+			} else {
+				items = append(items, fmt.Sprintf(`{"index":{"status":%d}}`, status))
+			}
+		}
+		_, _ = fmt.Fprintf(w, `{"took":1,"errors":%v,"items":[%s]}`, errors, strings.Join(items, ","))
+	}))
+
+	return server, func() []bulkRequest {
+		lock.Lock()
+		defer lock.Unlock()
+		return requests
+	}
+}
+
+func writeElasticsearchRows(w *elasticsearchWriter, messages ...string) error {
+	f := NewElasticsearchFormatter("logs")
+	var payload bytes.Buffer
+	for _, message := range messages {
+		payload.WriteString(f.FormatRow(time.Now(), "info", message))
+		payload.WriteString("\n")
+	}
+
+	_, err := w.Write(payload.Bytes())
+	return err
+}
+
+func TestElasticsearchWriter_SendsBulk(t *testing.T) {
+	server, requests := newElasticsearchServer()
+	defer server.Close()
+
+	output := NewBulkOutput(NewElasticsearchWriter(NewHttpWriter(server.URL)), NewElasticsearchFormatter("logs"), 2)
+	logger := GetLogger().WithOutput(output)
+	logger.Info("foo")
+	logger.Info("bar")
+	require.NoError(t, output.Flush())
+
+	require.Equal(t, []bulkRequest{{"application/x-ndjson", []string{"foo", "bar"}}}, requests())
+}
+
+func TestElasticsearchWriter_RetriesOnlyFailedItems(t *testing.T) {
+	server, requests := newElasticsearchServer([]int{201, 429, 201, 503}, []int{201, 500})
+	defer server.Close()
+
+	err := writeElasticsearchRows(NewElasticsearchWriter(NewHttpWriter(server.URL).WithRetries(fastRetries)), "a", "b", "c", "d")
+	require.NoError(t, err)
+
+	sent := requests()
+	require.Len(t, sent, 3)
+	require.Equal(t, []string{"a", "b", "c", "d"}, sent[0].messages)
+	require.Equal(t, []string{"b", "d"}, sent[1].messages)
+	require.Equal(t, []string{"d"}, sent[2].messages)
+}
+
+func TestElasticsearchWriter_ReportsRejectedItemsWithoutRetrying(t *testing.T) {
+	server, requests := newElasticsearchServer([]int{201, 400, 201})
+	defer server.Close()
+
+	err := writeElasticsearchRows(NewElasticsearchWriter(NewHttpWriter(server.URL).WithRetries(fastRetries)), "a", "b", "c")
+	require.EqualError(t, err, `Elasticsearch rejected 1 rows, first reason: {"type":"status_400"}`)
+	require.Len(t, requests(), 1)
+}
+
+func TestElasticsearchWriter_GivesUpOnItemsAfterMaxAttempts(t *testing.T) {
+	server, requests := newElasticsearchServer([]int{429}, []int{429}, []int{429}, []int{429})
+	defer server.Close()
+
+	err := writeElasticsearchRows(NewElasticsearchWriter(NewHttpWriter(server.URL).WithRetries(fastRetries)), "a")
+	require.EqualError(t, err, `Elasticsearch rejected 1 rows, first reason: {"type":"status_429"}`)
+	require.Len(t, requests(), 3)
+}
+
+func TestElasticsearchWriter_RejectsMalformedResponses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"errors": true, "items": []interface{}{}})
+	}))
+	defer server.Close()
+
+	err := writeElasticsearchRows(NewElasticsearchWriter(NewHttpWriter(server.URL)), "a")
+	require.EqualError(t, err, "bulk response has 0 items for 1 rows")
+}
@@ -0,0 +1,141 @@
+// Copyright 2019 the orbs-network-go authors
+// This file is part of the orbs-network-go library in the Orbs project.
+//
+// This source code is licensed under the MIT license found in the LICENSE file in the root directory of this source tree.
+// The above notice should be included in all copies or substantial portions of the software.
+
+package log
+
+import (
+	"fmt"
+	"runtime"
+	"strconv"
+
+	"github.com/orbs-network/gojay"
+	"github.com/pkg/errors"
+)
+
+// guards against errors that unwrap into themselves
+const MAX_ERROR_CAUSES = 100
+
+// implemented by errors created or wrapped with github.com/pkg/errors
+type stackTracer interface {
+	StackTrace() errors.StackTrace
+}
+
+type errorCause struct {
+	message   string
+	errorType string
+}
+
+type errorCauses []errorCause
+
+// errorDetails are what error fields are formatted with in addition to their message
+type errorDetails struct {
+	errorType string
+	stack     []string
+	causes    errorCauses
+}
+
+// unwrapError follows both Go 1.13 wrapping and github.com/pkg/errors causes
+func unwrapError(err error) error {
+	switch e := err.(type) {
+	case interface{ Unwrap() error }:
+		return e.Unwrap()
+	case interface{ Cause() error }:
+		return e.Cause()
+	default:
+		return nil
+	}
+}
+
+// the stack is that of the innermost error that has one, which is the closest to where the error happened.
+// causes are the errors err wraps, outermost first, leaving out wrappers that only add a stack to the error they wrap
+func detailsOf(err error) *errorDetails {
+	details := &errorDetails{errorType: fmt.Sprintf("%T", err)}
+
+	message := err.Error()
+	for e, i := err, 0; e != nil && i < MAX_ERROR_CAUSES; e, i = unwrapError(e), i+1 {
+		if tracer, ok := e.(stackTracer); ok {
+			details.stack = formatStackTrace(tracer.StackTrace())
+		}
+
+		if i > 0 && e.Error() != message {
+			message = e.Error()
+			details.causes = append(details.causes, errorCause{message: message, errorType: fmt.Sprintf("%T", e)})
+		}
+	}
+
+	return details
+}
+
+// frames are formatted as "function file:line"
+func formatStackTrace(stack errors.StackTrace) []string {
+	frames := make([]string, 0, len(stack))
+	for _, frame := range stack {
+		pc := uintptr(frame) - 1
+		fn := runtime.FuncForPC(pc)
+		if fn == nil {
+			continue
+		}
+
+		file, line := fn.FileLine(pc)
+		frames = append(frames, fn.Name()+" "+file+":"+strconv.Itoa(line))
+	}
+
+	return frames
+}
+
+// writes the message, type, stack and causes of err as key.message, key.type and so on, or as an object under key if nested
+func encodeError(enc *gojay.Encoder, key string, err error, nested bool) {
+	if nested {
+		enc.ObjectKey(key, &encodedError{err})
+		return
+	}
+
+	encodeErrorFields(enc, key+".", err)
+}
+
+type encodedError struct {
+	err error
+}
+
+func (e *encodedError) MarshalJSONObject(enc *gojay.Encoder) {
+	encodeErrorFields(enc, "", e.err)
+}
+
+func (e *encodedError) IsNil() bool {
+	return e == nil
+}
+
+func encodeErrorFields(enc *gojay.Encoder, prefix string, err error) {
+	details := detailsOf(err)
+
+	enc.StringKey(prefix+"message", err.Error())
+	enc.StringKey(prefix+"type", details.errorType)
+	if len(details.stack) > 0 {
+		enc.SliceStringKey(prefix+"stack", details.stack)
+	}
+	if len(details.causes) > 0 {
+		enc.ArrayKey(prefix+"causes", details.causes)
+	}
+}
+
+func (c errorCauses) MarshalJSONArray(enc *gojay.Encoder) {
+	for i := range c {
+		enc.Object(&c[i])
+	}
+}
+
+func (c errorCauses) IsNil() bool {
+	return len(c) == 0
+}
+
+func (c *errorCause) MarshalJSONObject(enc *gojay.Encoder) {
+	enc.StringKey("message", c.message)
+	enc.StringKey("type", c.errorType)
+}
+
+func (c *errorCause) IsNil() bool {
+	return c == nil
+}
@@ -0,0 +1,82 @@
+// Copyright 2019 the orbs-network-go authors
+// This file is part of the orbs-network-go library in the Orbs project.
+//
+// This source code is licensed under the MIT license found in the LICENSE file in the root directory of this source tree.
+// The above notice should be included in all copies or substantial portions of the software.
+
+package log
+
+import (
+	stderrors "errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+type wrappedError struct {
+	message string
+	cause   error
+}
+
+func (e *wrappedError) Error() string {
+	return e.message + ": " + e.cause.Error()
+}
+
+func (e *wrappedError) Unwrap() error {
+	return e.cause
+}
+
+func failToRead() error {
+	return errors.Wrap(io.EOF, "failed to read block")
+}
+
+func TestErrorDetails_StackAndCauses(t *testing.T) {
+	err := &wrappedError{message: "sync failed", cause: failToRead()}
+
+	details := detailsOf(err)
+	require.Equal(t, "*log.wrappedError", details.errorType)
+	require.Regexp(t, `^github.com/orbs-network/scribe/log.failToRead .*log/error_details_test.go:\d+$`, details.stack[0])
+	require.Equal(t, errorCauses{
+		{message: "failed to read block: EOF", errorType: "*errors.withStack"},
+		{message: "EOF", errorType: "*errors.errorString"},
+	}, details.causes)
+}
+
+func TestErrorDetails_WithoutStackOrCauses(t *testing.T) {
+	details := detailsOf(stderrors.New("foo"))
+
+	require.Equal(t, "*errors.errorString", details.errorType)
+	require.Empty(t, details.stack)
+	require.Empty(t, details.causes)
+}
+
+func TestJsonFormatter_ErrorDetails(t *testing.T) {
+	err := &wrappedError{message: "sync failed", cause: failToRead()}
+
+	flat := parseOutput(NewJsonFormatter().FormatRow(time.Now(), "error", "foo", Error(err)))
+	require.Equal(t, "sync failed: failed to read block: EOF", flat["error.message"])
+	require.Equal(t, "*log.wrappedError", flat["error.type"])
+	require.Regexp(t, "log.failToRead", flat["error.stack"].([]interface{})[0])
+	require.Len(t, flat["error.causes"], 2)
+	require.Equal(t, map[string]interface{}{"message": "EOF", "type": "*errors.errorString"}, flat["error.causes"].([]interface{})[1])
+	require.NotContains(t, flat, "error")
+
+	nested := parseOutput(NewJsonFormatter().WithNestedAggregates().FormatRow(time.Now(), "error", "foo", Error(stderrors.New("bar"))))
+	require.Equal(t, map[string]interface{}{"message": "bar", "type": "*errors.errorString"}, nested["error"])
+}
+
+func TestHumanReadableFormatter_PrintsStacksOnFollowingLines(t *testing.T) {
+	row := NewHumanReadableFormatter().FormatRow(time.Now(), "error", "foo", Error(failToRead()), Int("x", 1))
+
+	lines := strings.Split(row, "\n")
+	require.Contains(t, lines[0], "error=failed to read block: EOF")
+	require.Contains(t, lines[0], "x=1")
+	require.True(t, len(lines) > 1)
+	require.Regexp(t, `^\tgithub.com/orbs-network/scribe/log.failToRead `, lines[1])
+
+	require.NotContains(t, NewHumanReadableFormatter().FormatRow(time.Now(), "error", "foo", Error(stderrors.New("bar"))), "\n")
+}
@@ -47,6 +47,10 @@ const (
 	StringArrayType
 	TimeType
 	AggregateType
+	TraceIDType
+	SpanIDType
+	TraceFlagsType
+	SecretType
 )
 
 func (f *Field) Equal(other *Field) bool {
@@ -71,6 +75,19 @@ func Source(value string) *Field {
 	return &Field{Key: "source", StringVal: value, Type: SourceType}
 }
 
+// TraceID, SpanID and TraceFlags are usually added by the *Ctx logger methods, see TraceFields
+func TraceID(value string) *Field {
+	return &Field{Key: "trace_id", StringVal: value, Type: TraceIDType}
+}
+
+func SpanID(value string) *Field {
+	return &Field{Key: "span_id", StringVal: value, Type: SpanIDType}
+}
+
+func TraceFlags(value string) *Field {
+	return &Field{Key: "trace_flags", StringVal: value, Type: TraceFlagsType}
+}
+
 func String(key string, value string) *Field {
 	return &Field{Key: key, StringVal: value, Type: StringType}
 }
@@ -157,8 +174,12 @@ func (f *Field) Value() interface{} {
 		return f.StringVal
 	case SourceType:
 		return f.StringVal
+	case TraceIDType, SpanIDType, TraceFlagsType:
+		return f.StringVal
 	case StringType:
 		return f.StringVal
+	case SecretType:
+		return REDACTED
 	case IntType:
 		return f.Int
 	case TimeType:
@@ -192,6 +213,38 @@ func (f *Field) String() string {
 	return fmt.Sprintf("Field: key=%s, value=%v", f.Key, f.Value())
 }
 
+// visitFields calls visit for every field, descending into aggregates, and stops once visit returns false
+func visitFields(fields []*Field, visit func(f *Field) bool) bool {
+	for _, f := range fields {
+		if f == nil {
+			continue
+		}
+		if f.IsNested() {
+			if !visitFields(f.Nested.NestedFields(), visit) {
+				return false
+			}
+		} else if !visit(f) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// findField returns the first field, including fields inside aggregates, that matches condition
+func findField(fields []*Field, condition func(f *Field) bool) *Field {
+	var found *Field
+	visitFields(fields, func(f *Field) bool {
+		if condition(f) {
+			found = f
+			return false
+		}
+		return true
+	})
+
+	return found
+}
+
 func Aggregate(name string, fields ...*Field) *Field {
 	return &Field{Key: name, Nested: &aggregateField{fields: fields}, Type: AggregateType}
 }
@@ -8,6 +8,7 @@ package log
 
 import (
 	"regexp"
+	"sync/atomic"
 )
 
 type Filter interface {
@@ -20,6 +21,11 @@ type ConditionalFilter interface {
 	Off()
 }
 
+// implemented by conditional filters that can tell whether they are on, such as the ones NewConditionalFilter returns
+type toggledFilter interface {
+	IsOn() bool
+}
+
 func ExcludeEntryPoint(name string) Filter {
 	return ExcludeField(String("entry-point", name))
 }
@@ -75,24 +81,20 @@ func OnlyMetrics() Filter {
 	return &onlyMetrics{}
 }
 
+// MinLevel rejects rows with a severity lower than level; rows with levels that are not severities (such as metrics) are allowed
+func MinLevel(level Level) Filter {
+	return &minLevel{level}
+}
+
 type errorRegexp struct {
 	pattern         string
 	compiledPattern *regexp.Regexp
 }
 
 func (f *errorRegexp) Allows(level string, message string, fields []*Field) bool {
-	for _, field := range fields {
-		if field.Type == ErrorType {
-			if f.compiledPattern == nil {
-				return false
-			}
-			if f.compiledPattern.MatchString(field.Error.Error()) {
-				return false
-			}
-		}
-	}
-
-	return true
+	return findField(fields, func(field *Field) bool {
+		return field.Type == ErrorType && (f.compiledPattern == nil || f.compiledPattern.MatchString(field.Error.Error()))
+	}) == nil
 }
 
 type messageRegexp struct {
@@ -111,17 +113,13 @@ type onlyErrors struct {
 }
 
 func (f *onlyErrors) Allows(level string, message string, fields []*Field) bool {
-	if level == "error" {
+	if isErrorLevel(level) {
 		return true
 	}
 
-	for _, f := range fields {
-		if f.Type == ErrorType {
-			return true
-		}
-	}
-
-	return false
+	return findField(fields, func(field *Field) bool {
+		return field.Type == ErrorType
+	}) != nil
 }
 
 type includeFieldWithKey struct {
@@ -129,13 +127,9 @@ type includeFieldWithKey struct {
 }
 
 func (f *includeFieldWithKey) Allows(level string, message string, fields []*Field) bool {
-	for _, p := range fields {
-		if p.Key == f.key {
-			return true
-		}
-	}
-
-	return false
+	return findField(fields, func(field *Field) bool {
+		return field.Key == f.key
+	}) != nil
 }
 
 type excludeField struct {
@@ -143,17 +137,7 @@ type excludeField struct {
 }
 
 func (f *excludeField) Allows(level string, message string, fields []*Field) bool {
-	for _, p := range fields {
-		if p.IsNested() {
-			return f.Allows(level, message, p.Nested.NestedFields())
-		}
-		if p.Equal(f.field) {
-			return false
-		}
-
-	}
-
-	return true
+	return findField(fields, f.field.Equal) == nil
 }
 
 type matchField struct {
@@ -161,13 +145,7 @@ type matchField struct {
 }
 
 func (f *matchField) Allows(level string, message string, fields []*Field) bool {
-	for _, p := range fields {
-		if p.Equal(f.field) {
-			return true
-		}
-	}
-
-	return false
+	return findField(fields, f.field.Equal) != nil
 }
 
 type or struct {
@@ -211,25 +189,42 @@ func (f onlyMetrics) Allows(level string, message string, fields []*Field) bool
 	return level == "metric"
 }
 
+type minLevel struct {
+	level Level
+}
+
+func (f *minLevel) Allows(level string, message string, fields []*Field) bool {
+	return isLevelAtLeast(level, f.level)
+}
+
+// enabled is accessed atomically so that the filter can be toggled (see NewAdminHandler) while rows are being logged
 type conditionalFilter struct {
-	enabled bool
+	enabled int32
 	filter  Filter
 }
 
 func NewConditionalFilter(enabled bool, filter Filter) ConditionalFilter {
-	return &conditionalFilter{enabled, filter}
+	f := &conditionalFilter{filter: filter}
+	if enabled {
+		f.On()
+	}
+	return f
 }
 
 func (f *conditionalFilter) On() {
-	f.enabled = true
+	atomic.StoreInt32(&f.enabled, 1)
 }
 
 func (f *conditionalFilter) Off() {
-	f.enabled = false
+	atomic.StoreInt32(&f.enabled, 0)
+}
+
+func (f *conditionalFilter) IsOn() bool {
+	return atomic.LoadInt32(&f.enabled) == 1
 }
 
 func (f *conditionalFilter) Allows(level string, message string, fields []*Field) bool {
-	if f.enabled && f.filter != nil {
+	if f.IsOn() && f.filter != nil {
 		return f.filter.Allows(level, message, fields)
 	}
 
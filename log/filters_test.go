@@ -25,6 +25,11 @@ func TestFilters(t *testing.T) {
 		{"ExcludeFieldAllowsOtherParam", ExcludeField(Service("foo")), "", "", []*Field{Service("food")}, true},
 		{"ExcludeFieldAllowsWithNoParams", ExcludeField(Service("foo")), "", "", nil, true},
 		{"ExcludeFieldHandlesNestedParams", ExcludeField(Service("foo")), "", "", aggregatedTestFields(Service("foo")), false},
+		{"ExcludeFieldChecksFieldsAfterNestedParams", ExcludeField(Service("foo")), "", "", append(aggregatedTestFields(Service("bar")), Service("foo")), false},
+		{"IncludeParamWithKeyAllowsNestedKey", IncludeFieldWithKey("foo"), "", "", aggregatedTestFields(String("foo", "")), true},
+		{"OnlyErrorsAllowsInfoWithNestedErrorParam", OnlyErrors(), "info", "", aggregatedTestFields(Error(errors.Errorf("foo"))), true},
+		{"MatchFieldAllowsNestedField", MatchField(String("hello", "world")), "info", "", aggregatedTestFields(String("hello", "world")), true},
+		{"IgnoreErrorsMatchingRejectsNestedError", IgnoreErrorsMatching("foo.*"), "", "", aggregatedTestFields(Error(errors.Errorf("food"))), false},
 		{"IncludeParamWithKeyAllowsExpectedKey", IncludeFieldWithKey("foo"), "", "", []*Field{String("foo", "")}, true},
 		{"IncludeParamWithKeyRejectsWhenExpectedKeyNotFound", IncludeFieldWithKey("foo"), "", "", nil, false},
 		{"OnlyErrorsRejectsInfo", OnlyErrors(), "info", "", nil, false},
@@ -41,6 +46,15 @@ func TestFilters(t *testing.T) {
 		{"OrAllowsErrors", Or(OnlyErrors(), OnlyCheckpoints()), "error", "", nil, true},
 		{"OrAllowsCheckpoints", Or(OnlyErrors(), OnlyCheckpoints()), "info", "", []*Field{String("flow", "checkpoint")}, true},
 		{"OrRejectsNonErrorNonCheckpoint", Or(OnlyErrors(), OnlyCheckpoints()), "info", "", nil, false},
+		{"OnlyErrorsAllowsFatal", OnlyErrors(), "fatal", "", nil, true},
+		{"MinLevelRejectsLowerLevel", MinLevel(WarnLevel), "info", "", nil, false},
+		{"MinLevelAllowsSameLevel", MinLevel(WarnLevel), "warn", "", nil, true},
+		{"MinLevelAllowsHigherLevel", MinLevel(WarnLevel), "error", "", nil, true},
+		{"MinLevelAllowsMetrics", MinLevel(FatalLevel), "metric", "", nil, true},
+		{"MinLevelRejectsUppercaseLowerLevel", MinLevel(WarnLevel), "INFO", "", nil, false},
+		{"MinLevelAllowsWarningAlias", MinLevel(WarnLevel), "warning", "", nil, true},
+		{"OnlyErrorsAllowsUppercaseError", OnlyErrors(), "ERROR", "", nil, true},
+		{"OnlyErrorsAllowsCriticalAlias", OnlyErrors(), "critical", "", nil, true},
 	}
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
@@ -49,6 +63,20 @@ func TestFilters(t *testing.T) {
 	}
 }
 
+func TestParseLevel(t *testing.T) {
+	for name, level := range map[string]Level{
+		"trace": TraceLevel, "DEBUG": DebugLevel, "Info": InfoLevel, "WARN": WarnLevel, "warning": WarnLevel,
+		"err": ErrorLevel, "Error": ErrorLevel, "crit": FatalLevel, "panic": FatalLevel, "fatal": FatalLevel,
+	} {
+		parsed, err := ParseLevel(name)
+		require.NoError(t, err, name)
+		require.Equal(t, level, parsed, name)
+	}
+
+	_, err := ParseLevel("metric")
+	require.Error(t, err)
+}
+
 func aggregatedTestFields(fields ...*Field) []*Field {
 	return []*Field{Aggregate("baz", fields...)}
 }
@@ -10,9 +10,14 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"hash/fnv"
+	"io"
+	"os"
 	"strconv"
 	"strings"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/go-playground/ansi"
 	"github.com/orbs-network/gojay"
@@ -24,6 +29,8 @@ type LogFormatter interface {
 
 type jsonFormatter struct {
 	timestampColumn string
+	nestAggregates  bool
+	keys            map[FieldType]string
 }
 
 type logTimeStamp struct {
@@ -33,10 +40,12 @@ type logTimeStamp struct {
 
 // Defining a log line type so that we can use a much faster JSON marshall-ing package
 type logData struct {
-	level     string
-	timestamp *logTimeStamp
-	message   string
-	params    []*Field
+	level          string
+	timestamp      *logTimeStamp
+	message        string
+	params         []*Field
+	nestAggregates bool
+	keys           map[FieldType]string
 }
 
 // Implementing Marshaler
@@ -45,32 +54,69 @@ func (m *logData) MarshalJSONObject(enc *gojay.Encoder) {
 	enc.StringKey(m.timestamp.key, m.timestamp.value)
 	enc.StringKey("message", m.message)
 
-	for _, v := range m.params {
+	encodeFields(enc, m.params, m.nestAggregates, m.keys)
+}
+
+type nestedLogData struct {
+	params []*Field
+	keys   map[FieldType]string
+}
+
+func (m *nestedLogData) MarshalJSONObject(enc *gojay.Encoder) {
+	encodeFields(enc, m.params, true, m.keys)
+}
+
+func (m *nestedLogData) IsNil() bool {
+	return m == nil
+}
+
+// keys renames fields by type, see jsonFormatter.WithTraceKeys
+func encodeFields(enc *gojay.Encoder, params []*Field, nestAggregates bool, keys map[FieldType]string) {
+	for _, v := range params {
+		if v.IsNested() {
+			if nestAggregates {
+				enc.ObjectKey(v.Key, &nestedLogData{v.Nested.NestedFields(), keys})
+			} else {
+				encodeFields(enc, v.Nested.NestedFields(), false, keys)
+			}
+			continue
+		}
+
+		key := v.Key
+		if renamed, ok := keys[v.Type]; ok {
+			key = renamed
+		}
+
+		if v.Type == ErrorType && v.Error != nil {
+			encodeError(enc, key, v.Error, nestAggregates)
+			continue
+		}
+
 		switch vv := v.Value().(type) {
 		case string:
-			enc.StringKey(v.Key, vv)
+			enc.StringKey(key, vv)
 		case []string:
-			enc.AddSliceStringKey(v.Key, vv)
+			enc.AddSliceStringKey(key, vv)
 		case int:
-			enc.IntKey(v.Key, vv)
+			enc.IntKey(key, vv)
 		case int32:
-			enc.Int32Key(v.Key, vv)
+			enc.Int32Key(key, vv)
 		case int64:
-			enc.Int64Key(v.Key, vv)
+			enc.Int64Key(key, vv)
 		case uint:
 			uIntVal, _ := v.Value().(uint16)
-			enc.Uint16Key(v.Key, uIntVal)
+			enc.Uint16Key(key, uIntVal)
 		case uint32:
-			enc.Uint32Key(v.Key, vv)
+			enc.Uint32Key(key, vv)
 		case uint64:
-			enc.Uint64Key(v.Key, vv)
+			enc.Uint64Key(key, vv)
 		case float32:
-			enc.Float32Key(v.Key, vv)
+			enc.Float32Key(key, vv)
 		case float64:
-			enc.Float64Key(v.Key, vv)
+			enc.Float64Key(key, vv)
 		default:
 			// We 'force' all other types to convert into string
-			enc.StringKey(v.Key, fmt.Sprintf("%v", vv))
+			enc.StringKey(key, fmt.Sprintf("%v", vv))
 		}
 	}
 }
@@ -98,6 +144,8 @@ func (j *jsonFormatter) FormatRow(timestamp time.Time, level string, message str
 	l.timestamp = ts
 	l.message = message
 	l.params = params
+	l.nestAggregates = j.nestAggregates
+	l.keys = j.keys
 
 	if err := enc.Encode(l); err != nil {
 		return ""
@@ -117,7 +165,34 @@ func (j *jsonFormatter) WithTimestampColumn(column string) *jsonFormatter {
 	return j
 }
 
+// WithTraceKeys renames the trace_id, span_id and trace_flags fields, for example to match what a tracing backend expects
+func (j *jsonFormatter) WithTraceKeys(traceID string, spanID string, traceFlags string) *jsonFormatter {
+	j.keys = map[FieldType]string{
+		TraceIDType:    traceID,
+		SpanIDType:     spanID,
+		TraceFlagsType: traceFlags,
+	}
+	return j
+}
+
+// WithNestedAggregates encodes Aggregate fields as JSON objects keyed by the aggregate name, instead of merging their fields into the row
+func (j *jsonFormatter) WithNestedAggregates() *jsonFormatter {
+	j.nestAggregates = true
+	return j
+}
+
+func (j *jsonFormatter) formatsAggregates() bool {
+	return j.nestAggregates
+}
+
 type humanReadableFormatter struct {
+	colors       bool
+	levelColors  bool
+	colorKeys    []string
+	keyColors    bool
+	timeFormat   string
+	location     *time.Location
+	hideCallSite bool
 }
 
 const (
@@ -136,10 +211,22 @@ func findFieldByType(fieldType FieldType, params []*Field) (index int, result *F
 }
 
 func printParam(builder *strings.Builder, param *Field) {
+	printParamWithPrefix(builder, "", param, false, "")
+}
+
+// with keyColors, keys are colored from keyPalette and rowColor is restored after each of them
+func printParamWithPrefix(builder *strings.Builder, prefix string, param *Field, keyColors bool, rowColor string) {
 	if param == nil {
 		return
 	}
 
+	if param.IsNested() {
+		for _, nested := range param.Nested.NestedFields() {
+			printParamWithPrefix(builder, prefix+param.Key+".", nested, keyColors, rowColor)
+		}
+		return
+	}
+
 	var value string
 
 	switch param.Type {
@@ -153,6 +240,10 @@ func printParam(builder *strings.Builder, param *Field) {
 		value = param.StringVal
 	case SourceType:
 		value = param.StringVal
+	case TraceIDType, SpanIDType, TraceFlagsType:
+		value = param.StringVal
+	case SecretType:
+		value = REDACTED
 	case IntType:
 		value = strconv.FormatInt(param.Int, 10)
 	case UintType:
@@ -176,7 +267,16 @@ func printParam(builder *strings.Builder, param *Field) {
 		}
 	}
 
+	if keyColors {
+		builder.WriteString(keyColorOf(prefix + param.Key))
+	}
+	builder.WriteString(prefix)
 	builder.WriteString(param.Key)
+	if keyColors {
+		builder.WriteString(string(ansi.BoldOff))
+		builder.WriteString(string(ansi.ResetForeground))
+		builder.WriteString(rowColor)
+	}
 	builder.WriteString(EQUALS)
 	builder.WriteString(value)
 	builder.WriteString(SPACE)
@@ -189,24 +289,9 @@ func cut(i int, params []*Field) []*Field {
 	return params
 }
 
-func extractParamByTypePrintAndRemove(params []*Field, ft FieldType, builder *strings.Builder) (*Field, []*Field) {
-	return extractParamByType(params, ft, true, true, builder)
-}
-
 func extractParamByTypeAndRemove(params []*Field, ft FieldType) (*Field, []*Field) {
-	return extractParamByType(params, ft, false, true, nil)
-}
-
-func extractParamByType(params []*Field, ft FieldType, shouldPrint, shouldRemove bool, builder *strings.Builder) (*Field, []*Field) {
 	if idx, param := findFieldByType(ft, params); param != nil {
-		if shouldPrint {
-			printParam(builder, param)
-		}
-		if shouldRemove {
-			params = cut(idx, params)
-		}
-
-		return param, params
+		return param, cut(idx, params)
 	}
 
 	return nil, params
@@ -224,56 +309,357 @@ func extractParamByConditionAndRemove(params []*Field, condition func(param *Fie
 	return results, newParams
 }
 
+// fields inside aggregates are printed with the aggregate's name as a dotted prefix
+func (j *humanReadableFormatter) formatsAggregates() bool {
+	return true
+}
+
 func (j *humanReadableFormatter) FormatRow(timestamp time.Time, level string, message string, params ...*Field) (formattedRow string) {
 	builder := strings.Builder{}
-	var mutableParams = make([]*Field, len(params)) // this is needed because extractParamByTypePrintAndRemove mutates the array
+	var mutableParams = make([]*Field, len(params)) // this is needed because extractParamByTypeAndRemove mutates the array
 	copy(mutableParams, params)
 
-	ts := timestamp.UTC().Format("15:04:05.000000")
+	ts := timestamp.In(j.location).Format(j.timeFormat)
 
-	builder.WriteString(colorize(mutableParams))
-	builder.WriteString(level[0:1])
-	builder.WriteString(SPACE)
+	var rowColor string
+	if j.colors {
+		rowColor = colorize(mutableParams, j.colorKeys)
+		builder.WriteString(rowColor)
+	}
+
+	if len(level) > 0 {
+		if levelColor := levelColorOf(level); j.colors && j.levelColors && levelColor != "" {
+			builder.WriteString(levelColor)
+			builder.WriteString(level[0:1])
+			builder.WriteString(string(ansi.ResetForeground))
+			builder.WriteString(rowColor)
+		} else {
+			builder.WriteString(level[0:1])
+		}
+		builder.WriteString(SPACE)
+	}
 	builder.WriteString(ts)
 	builder.WriteString(SPACE)
 
 	builder.WriteString(message)
 	builder.WriteString(SPACE)
 
-	_, mutableParams = extractParamByTypePrintAndRemove(mutableParams, NodeType, &builder)
-	_, mutableParams = extractParamByTypePrintAndRemove(mutableParams, ServiceType, &builder)
+	nodeParam, mutableParams := extractParamByTypeAndRemove(mutableParams, NodeType)
+	serviceParam, mutableParams := extractParamByTypeAndRemove(mutableParams, ServiceType)
 	functionParam, mutableParams := extractParamByTypeAndRemove(mutableParams, FunctionType)
 	sourceParam, mutableParams := extractParamByTypeAndRemove(mutableParams, SourceType)
 	underscoreParams, mutableParams := extractParamByConditionAndRemove(mutableParams, func(param *Field) bool {
 		return strings.Index(param.Key, "_") == 0
 	})
 
+	j.printParam(&builder, nodeParam, rowColor)
+	j.printParam(&builder, serviceParam, rowColor)
+
 	for _, p := range mutableParams {
-		printParam(&builder, p)
+		j.printParam(&builder, p, rowColor)
 	}
 
 	// append the function/source
-	printParam(&builder, functionParam)
-	printParam(&builder, sourceParam)
+	if !j.hideCallSite {
+		j.printParam(&builder, functionParam, rowColor)
+		j.printParam(&builder, sourceParam, rowColor)
+	}
 
 	for _, param := range underscoreParams {
-		printParam(&builder, param)
+		j.printParam(&builder, param, rowColor)
+	}
+
+	printErrorStacks(&builder, params)
+
+	if rowColor != "" {
+		builder.WriteString(string(ansi.Reset))
 	}
 	return builder.String()
 }
 
-func colorize(fields []*Field) string {
-	colors := []string{ansi.Cyan, ansi.Yellow, ansi.LightBlue, ansi.Magenta, ansi.LightYellow, ansi.LightRed, ansi.LightGreen, ansi.LightMagenta, ansi.Green}
-	for _, f := range fields {
-		if f.Key == "request-id" {
-			fourthBeforeLastChar := int(f.StringVal[len(f.StringVal)-4])
-			return colors[fourthBeforeLastChar%len(colors)]
+// stacks are printed on the lines following the row, one frame per line
+func printErrorStacks(builder *strings.Builder, params []*Field) {
+	visitFields(params, func(f *Field) bool {
+		if f.Type == ErrorType && f.Error != nil {
+			for _, frame := range detailsOf(f.Error).stack {
+				builder.WriteString("\n\t")
+				builder.WriteString(frame)
+			}
+		}
+		return true
+	})
+}
+
+func (j *humanReadableFormatter) printParam(builder *strings.Builder, param *Field, rowColor string) {
+	printParamWithPrefix(builder, "", param, j.colors && j.keyColors, rowColor)
+}
+
+var rowColors = []string{ansi.Cyan, ansi.Yellow, ansi.LightBlue, ansi.Magenta, ansi.LightYellow, ansi.LightRed, ansi.LightGreen, ansi.LightMagenta, ansi.Green}
+
+var keyPalette = []string{ansi.LightBlue, ansi.LightMagenta, ansi.LightCyan, ansi.LightGreen, ansi.LightYellow}
+
+// a key gets the same color in every row, so that the eye finds it quickly
+func keyColorOf(key string) string {
+	hash := fnv.New32a()
+	_, _ = hash.Write([]byte(key))
+	return keyPalette[hash.Sum32()%uint32(len(keyPalette))]
+}
+
+// rows are colored by the value of the first of colorKeys they have, or by trace id if they have none of them,
+// so that rows of the same request share a color
+func colorize(fields []*Field, colorKeys []string) string {
+	var seed string
+	for _, key := range colorKeys {
+		if f := findField(fields, func(f *Field) bool { return f.Key == key }); f != nil {
+			seed = logfmtValue(f)
+			break
+		}
+	}
+
+	if seed == "" {
+		if traceID := findField(fields, func(f *Field) bool { return f.Type == TraceIDType }); traceID != nil {
+			seed = traceID.StringVal
 		}
 	}
 
-	return ""
+	if seed == "" {
+		return ""
+	}
+
+	hash := fnv.New32a()
+	_, _ = hash.Write([]byte(seed))
+	return rowColors[hash.Sum32()%uint32(len(rowColors))]
+}
+
+func levelColorOf(level string) string {
+	l, ok := levelOf(level)
+	if !ok {
+		return ""
+	}
+
+	switch l {
+	case TraceLevel, DebugLevel:
+		return ansi.Blue
+	case InfoLevel:
+		return ansi.Green
+	case WarnLevel:
+		return ansi.Yellow
+	default:
+		return ansi.Red
+	}
 }
 
-func NewHumanReadableFormatter() LogFormatter {
-	return &humanReadableFormatter{}
+// NewHumanReadableFormatter formats rows for people reading them in a terminal: the level initial, the time of day in UTC,
+// the message, node and service, the other fields, and function and source last.
+// rows are colored by request-id (or by trace id), see the With methods for the rest of the options
+func NewHumanReadableFormatter() *humanReadableFormatter {
+	return &humanReadableFormatter{
+		colors:     true,
+		colorKeys:  []string{"request-id"},
+		timeFormat: "15:04:05.000000",
+		location:   time.UTC,
+	}
+}
+
+// WithColors turns all coloring on or off
+func (j *humanReadableFormatter) WithColors(enabled bool) *humanReadableFormatter {
+	j.colors = enabled
+	return j
+}
+
+// WithAutoColors colors rows only when w is a terminal and the NO_COLOR environment variable is not set, see https://no-color.org
+func (j *humanReadableFormatter) WithAutoColors(w io.Writer) *humanReadableFormatter {
+	j.colors = isTerminal(w) && os.Getenv("NO_COLOR") == ""
+	return j
+}
+
+// WithLevelColors colors the level initial by level, red for errors, yellow for warnings, green for info and blue for debug
+func (j *humanReadableFormatter) WithLevelColors() *humanReadableFormatter {
+	j.levelColors = true
+	return j
+}
+
+// WithColorKeys replaces request-id as the fields whose value picks the color of a row; the first key a row has wins
+func (j *humanReadableFormatter) WithColorKeys(keys ...string) *humanReadableFormatter {
+	j.colorKeys = keys
+	return j
+}
+
+// WithColorizedKeys prints every field key in a bright color of its own, so keys stand out from the values
+func (j *humanReadableFormatter) WithColorizedKeys() *humanReadableFormatter {
+	j.keyColors = true
+	return j
+}
+
+// WithTimeFormat formats timestamps with layout in location (local time if nil) instead of 15:04:05.000000 in UTC
+func (j *humanReadableFormatter) WithTimeFormat(layout string, location *time.Location) *humanReadableFormatter {
+	if location == nil {
+		location = time.Local
+	}
+	j.timeFormat = layout
+	j.location = location
+	return j
+}
+
+// WithoutCallSite leaves out the function and source fields
+func (j *humanReadableFormatter) WithoutCallSite() *humanReadableFormatter {
+	j.hideCallSite = true
+	return j
+}
+
+func isTerminal(w io.Writer) bool {
+	file, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+const (
+	LOGFMT_LEVEL_KEY     = "level"
+	LOGFMT_TIMESTAMP_KEY = "ts"
+	LOGFMT_MESSAGE_KEY   = "msg"
+)
+
+type logfmtFormatter struct {
+	keyOrder []string
+}
+
+func NewLogfmtFormatter() *logfmtFormatter {
+	return &logfmtFormatter{
+		keyOrder: []string{LOGFMT_LEVEL_KEY, LOGFMT_TIMESTAMP_KEY, LOGFMT_MESSAGE_KEY},
+	}
+}
+
+// WithKeyOrder sets the order of the level, ts and msg keys which start every row; keys left out keep their default relative order after the given ones
+func (f *logfmtFormatter) WithKeyOrder(keys ...string) *logfmtFormatter {
+	var order []string
+	for _, key := range append(keys, LOGFMT_LEVEL_KEY, LOGFMT_TIMESTAMP_KEY, LOGFMT_MESSAGE_KEY) {
+		if (key == LOGFMT_LEVEL_KEY || key == LOGFMT_TIMESTAMP_KEY || key == LOGFMT_MESSAGE_KEY) && !containsString(order, key) {
+			order = append(order, key)
+		}
+	}
+	f.keyOrder = order
+	return f
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *logfmtFormatter) formatsAggregates() bool {
+	return true
+}
+
+func (f *logfmtFormatter) FormatRow(timestamp time.Time, level string, message string, params ...*Field) (formattedRow string) {
+	builder := strings.Builder{}
+
+	for _, key := range f.keyOrder {
+		switch key {
+		case LOGFMT_LEVEL_KEY:
+			writeLogfmtPair(&builder, key, level)
+		case LOGFMT_TIMESTAMP_KEY:
+			writeLogfmtPair(&builder, key, timestamp.UTC().Format(time.RFC3339Nano))
+		case LOGFMT_MESSAGE_KEY:
+			writeLogfmtPair(&builder, key, message)
+		}
+	}
+
+	writeLogfmtFields(&builder, "", params)
+
+	return builder.String()
+}
+
+// aggregates that were not flattened by the logger are written with their name as a dotted prefix
+func writeLogfmtFields(builder *strings.Builder, prefix string, params []*Field) {
+	for _, param := range params {
+		if param == nil {
+			continue
+		}
+
+		if param.IsNested() {
+			writeLogfmtFields(builder, prefix+param.Key+".", param.Nested.NestedFields())
+			continue
+		}
+
+		writeLogfmtPair(builder, prefix+param.Key, logfmtValue(param))
+	}
+}
+
+func logfmtValue(param *Field) string {
+	switch param.Type {
+	case IntType:
+		return strconv.FormatInt(param.Int, 10)
+	case UintType:
+		return strconv.FormatUint(param.Uint, 10)
+	case FloatType:
+		return strconv.FormatFloat(param.Float, 'f', -1, 64)
+	case BytesType:
+		return hex.EncodeToString(param.Bytes)
+	case TimeType:
+		return time.Unix(0, param.Int).UTC().Format(time.RFC3339Nano)
+	case ErrorType:
+		if param.Error != nil {
+			return param.Error.Error()
+		}
+		return "<nil>"
+	case StringArrayType:
+		json, err := json.Marshal(param.StringArray)
+		if err != nil {
+			return ""
+		}
+		return string(json)
+	case SecretType:
+		return REDACTED
+	default:
+		return param.StringVal
+	}
+}
+
+func writeLogfmtPair(builder *strings.Builder, key string, value string) {
+	if builder.Len() > 0 {
+		builder.WriteString(SPACE)
+	}
+	builder.WriteString(logfmtKey(key))
+	builder.WriteString(EQUALS)
+	if logfmtNeedsQuoting(value) {
+		builder.WriteString(strconv.Quote(value))
+	} else {
+		builder.WriteString(value)
+	}
+}
+
+// keys can't be quoted, so characters that would break the pair are replaced
+func logfmtKey(key string) string {
+	if key == "" {
+		return "_"
+	}
+
+	return strings.Map(func(r rune) rune {
+		if r <= ' ' || r == '=' || r == '"' || r == utf8.RuneError {
+			return '_'
+		}
+		return r
+	}, key)
+}
+
+func logfmtNeedsQuoting(value string) bool {
+	if value == "" {
+		return true
+	}
+
+	for _, r := range value {
+		if r <= ' ' || r == '=' || r == '"' || r == '\\' || r == utf8.RuneError || !unicode.IsPrint(r) {
+			return true
+		}
+	}
+
+	return false
 }
@@ -2,12 +2,15 @@ package log
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"os"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/go-playground/ansi"
 	"github.com/stretchr/testify/require"
 
 	"github.com/orbs-network/gojay"
@@ -67,3 +70,89 @@ func TestFormatHumanReadableRow(t *testing.T) {
 	require.Equal(t, "i 01:23:45.123456 foobar ", row)
 }
 
+func TestHumanReadableFormatter_ColorsByAnyKeyWithoutPanickingOnShortValues(t *testing.T) {
+	f := NewHumanReadableFormatter()
+	require.NotPanics(t, func() {
+		f.FormatRow(time.Now(), "info", "foo", String("request-id", "a"))
+	})
+
+	f.WithColorKeys("user", "request-id")
+	row := f.FormatRow(time.Now(), "info", "foo", String("request-id", "r1"), Aggregate("ctx", String("user", "u1")))
+	sameUser := f.FormatRow(time.Now(), "info", "bar", String("request-id", "r2"), Aggregate("ctx", String("user", "u1")))
+
+	require.True(t, strings.HasSuffix(row, string(ansi.Reset)), "colored row should reset its color")
+	require.Equal(t, row[:strings.Index(row, "i ")], sameUser[:strings.Index(sameUser, "i ")])
+}
+
+func TestHumanReadableFormatter_Layout(t *testing.T) {
+	tm, err := time.Parse(TIMESTAMP_FORMAT, "2006-01-02T01:23:45.123456789Z")
+	require.NoError(t, err)
+
+	f := NewHumanReadableFormatter().
+		WithColors(false).
+		WithTimeFormat(time.RFC3339, time.FixedZone("IST", 2*60*60)).
+		WithoutCallSite()
+	row := f.FormatRow(tm, "warn", "foo", Node("node1"), String("request-id", "r1"), Function("main"), Source("main.go:1"), Int("x", 1))
+
+	require.Equal(t, "w 2006-01-02T03:23:45+02:00 foo node=node1 request-id=r1 x=1 ", row)
+}
+
+func TestHumanReadableFormatter_LevelColorsAndColorizedKeys(t *testing.T) {
+	f := NewHumanReadableFormatter().WithLevelColors().WithColorizedKeys()
+
+	row := f.FormatRow(time.Now(), "error", "foo", Int("x", 1))
+	require.True(t, strings.HasPrefix(row, ansi.Red+"e"+string(ansi.ResetForeground)), "level initial should be red")
+	require.Contains(t, row, keyColorOf("x")+"x"+string(ansi.BoldOff)+string(ansi.ResetForeground)+"=1")
+	require.NotEqual(t, keyColorOf("x"), keyColorOf("request-id"), "keys should not all share one color")
+
+	require.NotContains(t, f.WithColors(false).FormatRow(time.Now(), "error", "foo", Int("x", 1)), "\x1b")
+}
+
+func TestHumanReadableFormatter_AutoColorsOnlyOnTerminals(t *testing.T) {
+	f := NewHumanReadableFormatter().WithAutoColors(new(strings.Builder))
+	require.Equal(t, "i", f.FormatRow(time.Now(), "info", "foo", String("request-id", "r1"))[:1])
+}
+
+func TestGetLogger_HonorsNoColor(t *testing.T) {
+	defer os.Unsetenv("NO_COLOR")
+	require.NoError(t, os.Setenv("NO_COLOR", "1"))
+
+	output := GetLogger().(*basicLogger).outputs[0].(*basicOutput)
+	require.False(t, output.formatter.(*humanReadableFormatter).colors)
+}
+
+func TestFormatLogfmtRow(t *testing.T) {
+	f := NewLogfmtFormatter()
+	tm, err := time.Parse(TIMESTAMP_FORMAT, "2006-01-02T01:23:45.123456789Z")
+	require.NoError(t, err)
+
+	row := f.FormatRow(tm, "info", "Ground control to Major Tom",
+		String("node", "node1"),
+		String("empty", ""),
+		String("equation", "a=b"),
+		String("quote", `say "hi"`),
+		String("multiline", "line1\nline2"),
+		String("bad key", "ok"),
+		Int("int", -5),
+		Uint("uint", 5),
+		Float64("float", 1.5),
+		Bytes("bytes", []byte{1, 250}),
+		Error(errors.New("kaboom")),
+		Timestamp("at", tm),
+		StringableSlice("names", []stringable{{"David"}, {"Bowie"}}),
+		Aggregate("block", Int("height", 5), String("hash", "abc")))
+
+	require.Equal(t, `level=info ts=2006-01-02T01:23:45.123456789Z msg="Ground control to Major Tom" `+
+		`node=node1 empty="" equation="a=b" quote="say \"hi\"" multiline="line1\nline2" bad_key=ok `+
+		`int=-5 uint=5 float=1.5 bytes=01fa error=kaboom at=2006-01-02T01:23:45.123456789Z `+
+		`names="[\"David\",\"Bowie\"]" block.height=5 block.hash=abc`, row)
+}
+
+func TestFormatLogfmtRowWithKeyOrder(t *testing.T) {
+	f := NewLogfmtFormatter().WithKeyOrder(LOGFMT_TIMESTAMP_KEY, "unknown")
+	tm, err := time.Parse(TIMESTAMP_FORMAT, "2006-01-02T01:23:45Z")
+	require.NoError(t, err)
+
+	row := f.FormatRow(tm, "info", "foobar")
+	require.Equal(t, "ts=2006-01-02T01:23:45Z level=info msg=foobar", row)
+}
@@ -0,0 +1,437 @@
+// Copyright 2019 the orbs-network-go authors
+// This file is part of the orbs-network-go library in the Orbs project.
+//
+// This source code is licensed under the MIT license found in the LICENSE file in the root directory of this source tree.
+// The above notice should be included in all copies or substantial portions of the software.
+
+package log
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const SPOOL_FILE_SUFFIX = ".spool"
+
+type RetryPolicy struct {
+	// total number of attempts, including the first one
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+type httpWriter struct {
+	url        string
+	httpClient *http.Client
+
+	contentType   string
+	headers       http.Header
+	headerSources []func() (http.Header, error)
+
+	retries     RetryPolicy
+	compression *CompressionOptions
+
+	spoolDir     string
+	spoolSeq     uint64
+	spoolLock    sync.Mutex
+	onSpoolError func(err error)
+}
+
+type sendError struct {
+	err        error
+	retryable  bool
+	retryAfter time.Duration
+}
+
+func (e *sendError) Error() string {
+	return e.err.Error()
+}
+
+// with a spool, p is written once it was sent or spooled; failures to send it that end with it spooled,
+// and failures to replay the spool, are reported to the handler set with WithSpoolErrorHandler instead of returned
+func (w *httpWriter) Write(p []byte) (n int, err error) {
+	size := len(p)
+
+	if _, err := w.sendWithRetries(p); err != nil {
+		if w.spoolDir == "" {
+			return 0, err
+		}
+
+		path, spoolErr := w.spool(p)
+		if spoolErr != nil {
+			return 0, errors.Errorf("%s, and failed to spool them: %s", err, spoolErr)
+		}
+
+		w.reportSpoolError(errors.Errorf("%s, spooled to %s", err, path))
+		return size, nil
+	}
+
+	if w.spoolDir != "" {
+		// spooled bulks stay where they are until the next replay, so this write succeeded regardless
+		if err := w.ReplaySpool(); err != nil {
+			w.reportSpoolError(err)
+		}
+	}
+
+	return size, nil
+}
+
+func (w *httpWriter) reportSpoolError(err error) {
+	if w.onSpoolError != nil {
+		w.onSpoolError(err)
+	}
+}
+
+// returns the body of the response that accepted p
+func (w *httpWriter) sendWithRetries(p []byte) ([]byte, error) {
+	body, encoding, compressErr := w.compress(p)
+	if compressErr != nil {
+		return nil, errors.Errorf("Failed to compress logs: %s", compressErr)
+	}
+
+	attempts := w.retries.MaxAttempts
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	var err *sendError
+	for attempt := 0; attempt < attempts; attempt++ {
+		if attempt > 0 {
+			time.Sleep(w.backoff(attempt, err.retryAfter))
+		}
+
+		var response []byte
+		if response, err = w.send(body, encoding); err == nil {
+			return response, nil
+		} else if !err.retryable {
+			break
+		}
+	}
+
+	return nil, err
+}
+
+// jittered exponential backoff, unless the server said when to come back; either way no longer than MaxBackoff
+func (w *httpWriter) backoff(attempt int, retryAfter time.Duration) time.Duration {
+	if retryAfter > 0 {
+		if w.retries.MaxBackoff > 0 && retryAfter > w.retries.MaxBackoff {
+			return w.retries.MaxBackoff
+		}
+		return retryAfter
+	}
+
+	backoff := w.retries.InitialBackoff << uint(attempt-1)
+	if w.retries.MaxBackoff > 0 && (backoff > w.retries.MaxBackoff || backoff <= 0) {
+		backoff = w.retries.MaxBackoff
+	}
+
+	if backoff <= 0 {
+		return 0
+	}
+
+	return backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+}
+
+func (w *httpWriter) send(body []byte, encoding string) ([]byte, *sendError) {
+	req, err := http.NewRequest("POST", w.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, &sendError{err: errors.Errorf("Failed to send logs: %s", err)}
+	}
+	if err := w.setHeaders(req); err != nil {
+		return nil, &sendError{err: errors.Errorf("Failed to send logs: %s", err), retryable: true}
+	}
+	if encoding != "" {
+		req.Header.Set("Content-Encoding", encoding)
+	}
+
+	resp, err := w.httpClient.Do(req)
+
+	if err != nil {
+		return nil, &sendError{err: errors.Errorf("Failed to send logs: %s", err), retryable: true}
+	}
+
+	defer resp.Body.Close()
+	response, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, &sendError{err: errors.Errorf("Failed to read response: %s", err), retryable: true}
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		e := &sendError{
+			err:       errors.Errorf("Failed to send logs: %d", resp.StatusCode),
+			retryable: resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500,
+		}
+		if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+			e.retryAfter = parseRetryAfter(resp.Header.Get("Retry-After"))
+		}
+		return nil, e
+	}
+
+	return response, nil
+}
+
+// dynamic headers are applied last, so they override static ones
+func (w *httpWriter) setHeaders(req *http.Request) error {
+	req.Header.Set("Content-Type", w.contentType)
+
+	for key, values := range w.headers {
+		req.Header[key] = values
+	}
+
+	for _, source := range w.headerSources {
+		headers, err := source()
+		if err != nil {
+			return errors.Errorf("failed to get request headers: %s", err)
+		}
+		for key, values := range headers {
+			req.Header[http.CanonicalHeaderKey(key)] = values
+		}
+	}
+
+	return nil
+}
+
+// returns p as is, with an empty encoding, when compression is off or p is too small to bother
+func (w *httpWriter) compress(p []byte) ([]byte, string, error) {
+	if w.compression == nil || len(p) < w.compression.MinSize {
+		return p, "", nil
+	}
+
+	compressed, err := w.compression.Compressor.Compress(p, w.compression.Level)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return compressed, w.compression.Compressor.ContentEncoding(), nil
+}
+
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+
+	if t, err := http.ParseTime(value); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+
+	return 0
+}
+
+func (w *httpWriter) spool(p []byte) (string, error) {
+	if err := os.MkdirAll(w.spoolDir, 0755); err != nil {
+		return "", err
+	}
+
+	// names sort in the order the bulks were spooled
+	name := fmt.Sprintf("%020d-%010d%s", time.Now().UnixNano(), atomic.AddUint64(&w.spoolSeq, 1), SPOOL_FILE_SUFFIX)
+	path := filepath.Join(w.spoolDir, name)
+
+	// write to a temporary name first so that a replay never picks up a partial file
+	if err := ioutil.WriteFile(path+".tmp", p, 0644); err != nil {
+		return "", err
+	}
+
+	return path, os.Rename(path+".tmp", path)
+}
+
+// ReplaySpool sends spooled bulks oldest first, one attempt each, and stops at the first failure.
+// Write calls it after every successful send; it can also be called on startup to send what a previous process spooled
+func (w *httpWriter) ReplaySpool() error {
+	w.spoolLock.Lock()
+	defer w.spoolLock.Unlock()
+
+	files, err := ioutil.ReadDir(w.spoolDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	var names []string
+	for _, f := range files {
+		if !f.IsDir() && strings.HasSuffix(f.Name(), SPOOL_FILE_SUFFIX) {
+			names = append(names, f.Name())
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		path := filepath.Join(w.spoolDir, name)
+		p, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		body, encoding, err := w.compress(p)
+		if err != nil {
+			return err
+		}
+
+		if _, err := w.send(body, encoding); err != nil {
+			return errors.Errorf("failed to replay spooled logs from %s: %s", path, err)
+		}
+
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// WithRetries retries failed sends on network errors, 429 and 5xx responses, honoring Retry-After on 429 and 503 up to MaxBackoff
+func (w *httpWriter) WithRetries(policy RetryPolicy) *httpWriter {
+	w.retries = policy
+	return w
+}
+
+// WithCompression compresses bulks of at least opts.MinSize bytes and sets Content-Encoding accordingly
+func (w *httpWriter) WithCompression(opts CompressionOptions) *httpWriter {
+	if opts.Compressor == nil {
+		opts.Compressor = GzipCompressor()
+	}
+	w.compression = &opts
+	return w
+}
+
+// WithSpool writes bulks that failed all attempts to dir, to be replayed once the endpoint recovers
+func (w *httpWriter) WithSpool(dir string) *httpWriter {
+	w.spoolDir = dir
+	return w
+}
+
+// WithSpoolErrorHandler is called with the errors of bulks that were spooled instead of sent, and of failed replays of the spool
+func (w *httpWriter) WithSpoolErrorHandler(handler func(err error)) *httpWriter {
+	w.onSpoolError = handler
+	return w
+}
+
+func (w *httpWriter) WithContentType(contentType string) *httpWriter {
+	w.contentType = contentType
+	return w
+}
+
+// WithHeader adds a header that is sent with every request
+func (w *httpWriter) WithHeader(key string, value string) *httpWriter {
+	w.headers.Add(key, value)
+	return w
+}
+
+// WithHeaderSource calls source before every request (including retries), for headers that change over time such as refreshed tokens
+func (w *httpWriter) WithHeaderSource(source func() (http.Header, error)) *httpWriter {
+	w.headerSources = append(w.headerSources, source)
+	return w
+}
+
+func (w *httpWriter) WithBearerToken(token string) *httpWriter {
+	w.headers.Set("Authorization", "Bearer "+token)
+	return w
+}
+
+func (w *httpWriter) WithBasicAuth(username string, password string) *httpWriter {
+	req := &http.Request{Header: http.Header{}}
+	req.SetBasicAuth(username, password)
+	w.headers.Set("Authorization", req.Header.Get("Authorization"))
+	return w
+}
+
+// WithHttpClient replaces the client, including the timeout passed to NewHttpWriterWithTimeout
+func (w *httpWriter) WithHttpClient(client *http.Client) *httpWriter {
+	w.httpClient = client
+	return w
+}
+
+// WithRoundTripper sends requests through transport. the client is copied first, as it may be shared, for example when it is http.DefaultClient
+func (w *httpWriter) WithRoundTripper(transport http.RoundTripper) *httpWriter {
+	client := *w.httpClient
+	client.Transport = transport
+	w.httpClient = &client
+	return w
+}
+
+// WithTLSConfig applies config to a clone of the client's transport, which must be the default one or an *http.Transport; see NewTLSConfig
+func (w *httpWriter) WithTLSConfig(config *tls.Config) (*httpWriter, error) {
+	var transport *http.Transport
+	switch t := w.httpClient.Transport.(type) {
+	case nil:
+		defaultTransport, ok := http.DefaultTransport.(*http.Transport)
+		if !ok {
+			return w, errors.Errorf("can't apply TLS config to default round tripper of type %T", http.DefaultTransport)
+		}
+		transport = defaultTransport.Clone()
+	case *http.Transport:
+		transport = t.Clone()
+	default:
+		return w, errors.Errorf("can't apply TLS config to round tripper of type %T", t)
+	}
+
+	transport.TLSClientConfig = config
+	return w.WithRoundTripper(transport), nil
+}
+
+// NewTLSConfig trusts the CA certificates in caFile (if not empty) in addition to the system ones,
+// and presents the client certificate in certFile/keyFile (if not empty) for mutual TLS
+func NewTLSConfig(caFile string, certFile string, keyFile string) (*tls.Config, error) {
+	config := &tls.Config{}
+
+	if caFile != "" {
+		pem, err := ioutil.ReadFile(caFile)
+		if err != nil {
+			return nil, err
+		}
+
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.Errorf("no certificates found in %s", caFile)
+		}
+		config.RootCAs = pool
+	}
+
+	if certFile != "" || keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	return config, nil
+}
+
+func NewHttpWriter(url string) *httpWriter {
+	return NewHttpWriterWithTimeout(url, time.Second*60)
+}
+
+func NewHttpWriterWithTimeout(url string, timeout time.Duration) *httpWriter {
+	return &httpWriter{
+		url:         url,
+		httpClient:  &http.Client{Timeout: timeout},
+		contentType: "application/json",
+		headers:     http.Header{},
+	}
+}
@@ -0,0 +1,428 @@
+// Copyright 2019 the orbs-network-go authors
+// This file is part of the orbs-network-go library in the Orbs project.
+//
+// This source code is licensed under the MIT license found in the LICENSE file in the root directory of this source tree.
+// The above notice should be included in all copies or substantial portions of the software.
+
+package log
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/tls"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+var fastRetries = RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: 10 * time.Millisecond}
+
+// responds with the given statuses in order, and with 200 once they run out
+func newScriptedServer(statuses ...int) (*httptest.Server, *int32) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer r.Body.Close()
+		_, _ = ioutil.ReadAll(r.Body)
+
+		i := atomic.AddInt32(&requests, 1) - 1
+		if int(i) < len(statuses) {
+			w.WriteHeader(statuses[i])
+		} else {
+			w.WriteHeader(200)
+		}
+	}))
+
+	return server, &requests
+}
+
+func TestHttpWriter_RetriesServerErrors(t *testing.T) {
+	server, requests := newScriptedServer(500, 503)
+	defer server.Close()
+
+	w := NewHttpWriter(server.URL).WithRetries(fastRetries)
+	n, err := w.Write([]byte("hello"))
+
+	require.NoError(t, err)
+	require.EqualValues(t, 5, n)
+	require.EqualValues(t, 3, atomic.LoadInt32(requests))
+}
+
+func TestHttpWriter_GivesUpAfterMaxAttempts(t *testing.T) {
+	server, requests := newScriptedServer(500, 500, 500, 500)
+	defer server.Close()
+
+	w := NewHttpWriter(server.URL).WithRetries(fastRetries)
+	_, err := w.Write([]byte("hello"))
+
+	require.Error(t, err)
+	require.EqualValues(t, 3, atomic.LoadInt32(requests))
+}
+
+func TestHttpWriter_DoesNotRetryClientErrors(t *testing.T) {
+	server, requests := newScriptedServer(400)
+	defer server.Close()
+
+	w := NewHttpWriter(server.URL).WithRetries(fastRetries)
+	_, err := w.Write([]byte("hello"))
+
+	require.Error(t, err)
+	require.EqualValues(t, 1, atomic.LoadInt32(requests))
+}
+
+func TestHttpWriter_RespectsRetryAfter(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(200)
+	}))
+	defer server.Close()
+
+	w := NewHttpWriter(server.URL).WithRetries(RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Millisecond, MaxBackoff: 2 * time.Second})
+	start := time.Now()
+	_, err := w.Write([]byte("hello"))
+
+	require.NoError(t, err)
+	require.True(t, time.Since(start) >= time.Second, "did not wait for Retry-After")
+}
+
+func TestHttpWriter_CapsRetryAfterAtMaxBackoff(t *testing.T) {
+	w := NewHttpWriter("http://localhost").WithRetries(fastRetries)
+
+	require.Equal(t, fastRetries.MaxBackoff, w.backoff(1, time.Hour))
+	require.Equal(t, time.Hour, NewHttpWriter("http://localhost").backoff(1, time.Hour), "no MaxBackoff means no cap")
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	require.Equal(t, 3*time.Second, parseRetryAfter("3"))
+	require.Equal(t, time.Duration(0), parseRetryAfter(""))
+	require.Equal(t, time.Duration(0), parseRetryAfter("soon"))
+
+	d := parseRetryAfter(time.Now().Add(time.Minute).UTC().Format(http.TimeFormat))
+	require.True(t, d > 50*time.Second && d <= time.Minute)
+}
+
+func TestHttpWriter_SpoolsFailedBulksAndReplaysThemOnRecovery(t *testing.T) {
+	dir, err := ioutil.TempDir("", "httpWriterSpool")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	var lock sync.Mutex
+	var up bool
+	var received []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		lock.Lock()
+		defer lock.Unlock()
+		if !up {
+			w.WriteHeader(503)
+			return
+		}
+		received = append(received, string(body))
+		w.WriteHeader(200)
+	}))
+	defer server.Close()
+
+	var spoolErrors []error
+	w := NewHttpWriter(server.URL).WithRetries(fastRetries).WithSpool(dir).WithSpoolErrorHandler(func(err error) {
+		spoolErrors = append(spoolErrors, err)
+	})
+
+	_, err = w.Write([]byte("first"))
+	require.NoError(t, err, "a spooled bulk is not lost")
+	_, err = w.Write([]byte("second"))
+	require.NoError(t, err, "a spooled bulk is not lost")
+	require.Len(t, spoolErrors, 2)
+	require.Contains(t, spoolErrors[0].Error(), "spooled to")
+
+	files, _ := ioutil.ReadDir(dir)
+	require.Len(t, files, 2)
+
+	lock.Lock()
+	up = true
+	lock.Unlock()
+
+	_, err = w.Write([]byte("third"))
+	require.NoError(t, err)
+
+	require.Equal(t, []string{"third", "first", "second"}, received)
+	files, _ = ioutil.ReadDir(dir)
+	require.Empty(t, files)
+}
+
+func TestHttpWriter_ReportsReplayFailuresSeparately(t *testing.T) {
+	dir, err := ioutil.TempDir("", "httpWriterSpool")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if string(body) == "rejected" {
+			w.WriteHeader(400)
+			return
+		}
+		w.WriteHeader(200)
+	}))
+	defer server.Close()
+
+	var spoolErrors []error
+	w := NewHttpWriter(server.URL).WithSpool(dir).WithSpoolErrorHandler(func(err error) {
+		spoolErrors = append(spoolErrors, err)
+	})
+	_, err = w.spool([]byte("rejected"))
+	require.NoError(t, err)
+
+	n, err := w.Write([]byte("accepted"))
+
+	require.NoError(t, err, "the replay failed, not this write")
+	require.Equal(t, len("accepted"), n)
+	require.Len(t, spoolErrors, 1)
+	require.Contains(t, spoolErrors[0].Error(), "failed to replay")
+}
+
+type recordedRequest struct {
+	body    []byte
+	headers http.Header
+}
+
+func newRecordingServer() (*httptest.Server, chan *recordedRequest) {
+	requests := make(chan *recordedRequest, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		requests <- &recordedRequest{body, r.Header}
+		w.WriteHeader(200)
+	}))
+
+	return server, requests
+}
+
+func TestHttpWriter_CompressesWithGzip(t *testing.T) {
+	server, requests := newRecordingServer()
+	defer server.Close()
+
+	payload := []byte(strings.Repeat("Ground control to Major Tom\n", 100))
+	w := NewHttpWriter(server.URL).WithCompression(CompressionOptions{Level: gzip.BestCompression})
+	n, err := w.Write(payload)
+	require.NoError(t, err)
+	require.EqualValues(t, len(payload), n)
+
+	r := <-requests
+	require.Equal(t, "gzip", r.headers.Get("Content-Encoding"))
+	require.True(t, len(r.body) < len(payload))
+
+	reader, err := gzip.NewReader(bytes.NewReader(r.body))
+	require.NoError(t, err)
+	decompressed, err := ioutil.ReadAll(reader)
+	require.NoError(t, err)
+	require.Equal(t, payload, decompressed)
+}
+
+func TestHttpWriter_GzipLevelZeroStoresUncompressed(t *testing.T) {
+	server, requests := newRecordingServer()
+	defer server.Close()
+
+	payload := []byte(strings.Repeat("Ground control to Major Tom\n", 100))
+	w := NewHttpWriter(server.URL).WithCompression(CompressionOptions{Level: gzip.NoCompression})
+	_, err := w.Write(payload)
+	require.NoError(t, err)
+
+	r := <-requests
+	require.Equal(t, "gzip", r.headers.Get("Content-Encoding"))
+	require.True(t, len(r.body) > len(payload), "level 0 should not compress")
+
+	reader, err := gzip.NewReader(bytes.NewReader(r.body))
+	require.NoError(t, err)
+	decompressed, err := ioutil.ReadAll(reader)
+	require.NoError(t, err)
+	require.Equal(t, payload, decompressed)
+}
+
+func TestHttpWriter_CompressesWithDefaultLevel(t *testing.T) {
+	server, requests := newRecordingServer()
+	defer server.Close()
+
+	payload := []byte(strings.Repeat("Ground control to Major Tom\n", 100))
+	w := NewHttpWriter(server.URL).WithCompression(CompressionOptions{Level: DEFAULT_COMPRESSION_LEVEL})
+	_, err := w.Write(payload)
+	require.NoError(t, err)
+
+	r := <-requests
+	require.True(t, len(r.body) < len(payload))
+}
+
+func TestHttpWriter_SkipsCompressionBelowMinSize(t *testing.T) {
+	server, requests := newRecordingServer()
+	defer server.Close()
+
+	w := NewHttpWriter(server.URL).WithCompression(CompressionOptions{MinSize: 1024})
+	_, err := w.Write([]byte("hello"))
+	require.NoError(t, err)
+
+	r := <-requests
+	require.Empty(t, r.headers.Get("Content-Encoding"))
+	require.Equal(t, []byte("hello"), r.body)
+}
+
+type reversingCompressor struct {
+}
+
+func (reversingCompressor) ContentEncoding() string {
+	return "reversed"
+}
+
+func (reversingCompressor) Compress(p []byte, level int) ([]byte, error) {
+	reversed := make([]byte, len(p))
+	for i, b := range p {
+		reversed[len(p)-1-i] = b
+	}
+	return reversed, nil
+}
+
+func TestHttpWriter_UsesCustomCompressor(t *testing.T) {
+	server, requests := newRecordingServer()
+	defer server.Close()
+
+	w := NewHttpWriter(server.URL).WithCompression(CompressionOptions{Compressor: reversingCompressor{}})
+	_, err := w.Write([]byte("hello"))
+	require.NoError(t, err)
+
+	r := <-requests
+	require.Equal(t, "reversed", r.headers.Get("Content-Encoding"))
+	require.Equal(t, []byte("olleh"), r.body)
+}
+
+func TestHttpWriter_FailsOnInvalidCompressionLevel(t *testing.T) {
+	w := NewHttpWriter("http://127.0.0.1:0").WithCompression(CompressionOptions{Level: 42})
+	_, err := w.Write([]byte("hello"))
+	require.Error(t, err)
+}
+
+func TestHttpWriter_SendsConfiguredHeaders(t *testing.T) {
+	server, requests := newRecordingServer()
+	defer server.Close()
+
+	calls := 0
+	w := NewHttpWriter(server.URL).
+		WithContentType("application/x-ndjson").
+		WithHeader("X-Api-Key", "secret").
+		WithBasicAuth("major", "tom").
+		WithHeaderSource(func() (http.Header, error) {
+			calls++
+			return http.Header{"x-request-number": []string{fmt.Sprintf("%d", calls)}}, nil
+		})
+
+	_, err := w.Write([]byte("hello"))
+	require.NoError(t, err)
+	_, err = w.Write([]byte("hello"))
+	require.NoError(t, err)
+
+	r := <-requests
+	require.Equal(t, "application/x-ndjson", r.headers.Get("Content-Type"))
+	require.Equal(t, "secret", r.headers.Get("X-Api-Key"))
+	require.Equal(t, "Basic bWFqb3I6dG9t", r.headers.Get("Authorization"))
+	require.Equal(t, "1", r.headers.Get("X-Request-Number"))
+
+	r = <-requests
+	require.Equal(t, "2", r.headers.Get("X-Request-Number"))
+}
+
+func TestHttpWriter_FailsWhenHeaderSourceFails(t *testing.T) {
+	server, requests := newRecordingServer()
+	defer server.Close()
+
+	w := NewHttpWriter(server.URL).
+		WithBearerToken("token").
+		WithHeaderSource(func() (http.Header, error) {
+			return nil, fmt.Errorf("token expired")
+		})
+
+	_, err := w.Write([]byte("hello"))
+	require.Error(t, err)
+	require.Regexp(t, "token expired", err.Error())
+	require.Empty(t, requests)
+}
+
+type roundTripperFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestHttpWriter_UsesCustomRoundTripper(t *testing.T) {
+	var url string
+	w := NewHttpWriter("http://logs.example.com/submit").WithRoundTripper(roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		url = req.URL.String()
+		return &http.Response{StatusCode: 200, Body: ioutil.NopCloser(strings.NewReader(""))}, nil
+	}))
+
+	_, err := w.Write([]byte("hello"))
+	require.NoError(t, err)
+	require.Equal(t, "http://logs.example.com/submit", url)
+}
+
+func TestHttpWriter_TrustsConfiguredCA(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(200)
+	}))
+	defer server.Close()
+
+	_, err := NewHttpWriter(server.URL).Write([]byte("hello"))
+	require.Error(t, err, "server certificate should not be trusted by default")
+
+	caFile, err := ioutil.TempFile("", "httpWriterCA")
+	require.NoError(t, err)
+	defer os.Remove(caFile.Name())
+	require.NoError(t, pem.Encode(caFile, &pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}))
+	require.NoError(t, caFile.Close())
+
+	config, err := NewTLSConfig(caFile.Name(), "", "")
+	require.NoError(t, err)
+
+	w, err := NewHttpWriter(server.URL).WithTLSConfig(config)
+	require.NoError(t, err)
+	_, err = w.Write([]byte("hello"))
+	require.NoError(t, err)
+}
+
+func TestHttpWriter_WithTLSConfigLeavesSharedClientAndTransportAlone(t *testing.T) {
+	transport := &http.Transport{}
+	client := &http.Client{Transport: transport}
+
+	w, err := NewHttpWriter("https://logs.example.com").WithHttpClient(client).WithTLSConfig(&tls.Config{ServerName: "logs"})
+	require.NoError(t, err)
+
+	require.True(t, transport.TLSClientConfig == nil || transport.TLSClientConfig.ServerName == "", "shared transport was modified")
+	require.Equal(t, transport, client.Transport)
+	require.Equal(t, "logs", w.httpClient.Transport.(*http.Transport).TLSClientConfig.ServerName)
+
+	_, err = NewHttpWriter("https://logs.example.com").WithHttpClient(http.DefaultClient).WithTLSConfig(&tls.Config{})
+	require.NoError(t, err)
+	require.Nil(t, http.DefaultClient.Transport)
+}
+
+func TestHttpWriter_WithTLSConfigFailsOnUnsupportedRoundTripper(t *testing.T) {
+	_, err := NewHttpWriter("https://logs.example.com").WithRoundTripper(roundTripperFunc(nil)).WithTLSConfig(&tls.Config{})
+	require.Error(t, err)
+}
+
+func TestNewTLSConfig_FailsWithoutCertificatesInCAFile(t *testing.T) {
+	caFile, err := ioutil.TempFile("", "httpWriterCA")
+	require.NoError(t, err)
+	defer os.Remove(caFile.Name())
+
+	_, err = NewTLSConfig(caFile.Name(), "", "")
+	require.Error(t, err)
+}
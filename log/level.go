@@ -0,0 +1,96 @@
+// Copyright 2019 the orbs-network-go authors
+// This file is part of the orbs-network-go library in the Orbs project.
+//
+// This source code is licensed under the MIT license found in the LICENSE file in the root directory of this source tree.
+// The above notice should be included in all copies or substantial portions of the software.
+
+package log
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Level is an ordered severity. Rows still carry their level as a string (see Logger.Log),
+// so Level is used wherever severities need to be compared.
+type Level int32
+
+const (
+	TraceLevel Level = iota
+	DebugLevel
+	InfoLevel
+	WarnLevel
+	ErrorLevel
+	FatalLevel
+)
+
+var levelNames = []string{"trace", "debug", "info", "warn", "error", "fatal"}
+
+func (l Level) String() string {
+	if l < TraceLevel || l > FatalLevel {
+		return "unknown"
+	}
+	return levelNames[l]
+}
+
+func ParseLevel(level string) (Level, error) {
+	if l, ok := levelOf(level); ok {
+		return l, nil
+	}
+
+	return 0, errors.Errorf("unknown log level %s", level)
+}
+
+// rows usually carry the lowercase names produced by Level.String(), so those are matched first to keep this cheap on the logging path;
+// other cases and common aliases (such as "WARN" or "warning") are matched too, so that such rows are still subject to thresholds
+func levelOf(level string) (Level, bool) {
+	switch level {
+	case "trace":
+		return TraceLevel, true
+	case "debug":
+		return DebugLevel, true
+	case "info":
+		return InfoLevel, true
+	case "warn":
+		return WarnLevel, true
+	case "error":
+		return ErrorLevel, true
+	case "fatal":
+		return FatalLevel, true
+	}
+
+	switch strings.ToLower(level) {
+	case "trace":
+		return TraceLevel, true
+	case "debug", "dbg":
+		return DebugLevel, true
+	case "info", "information", "notice":
+		return InfoLevel, true
+	case "warn", "warning":
+		return WarnLevel, true
+	case "error", "err":
+		return ErrorLevel, true
+	case "fatal", "critical", "crit", "panic":
+		return FatalLevel, true
+	}
+
+	return 0, false
+}
+
+// levels that are not severities (for example "metric") are not subject to thresholds
+func isLevelAtLeast(level string, min Level) bool {
+	if l, ok := levelOf(level); ok {
+		return l >= min
+	}
+
+	return true
+}
+
+func isErrorLevel(level string) bool {
+	if l, ok := levelOf(level); ok {
+		return l >= ErrorLevel
+	}
+
+	return false
+}
@@ -7,6 +7,7 @@
 package log
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
@@ -16,14 +17,26 @@ import (
 
 type Logger interface {
 	Log(level string, message string, params ...*Field)
+	Debug(message string, params ...*Field)
 	Info(message string, params ...*Field)
+	Warn(message string, params ...*Field)
 	Error(message string, params ...*Field)
+	Fatal(message string, params ...*Field)
 	Metric(params ...*Field)
+	LogCtx(ctx context.Context, level string, message string, params ...*Field)
+	DebugCtx(ctx context.Context, message string, params ...*Field)
+	InfoCtx(ctx context.Context, message string, params ...*Field)
+	WarnCtx(ctx context.Context, message string, params ...*Field)
+	ErrorCtx(ctx context.Context, message string, params ...*Field)
+	FatalCtx(ctx context.Context, message string, params ...*Field)
 	WithTags(params ...*Field) Logger
 	Tags() []*Field
 	WithOutput(writer ...Output) Logger
+	Outputs() []Output
 	WithFilters(filter ...Filter) Logger
 	Filters() []Filter
+	WithRedactor(redactor ...Redactor) Logger
+	RecoverPanic(opts PanicOptions)
 }
 
 type basicLogger struct {
@@ -31,13 +44,14 @@ type basicLogger struct {
 	tags         []*Field
 	nestingLevel int
 	filters      []Filter
+	redactors    []Redactor
 }
 
 func GetLogger(params ...*Field) Logger {
 	logger := &basicLogger{
 		tags:         params,
 		nestingLevel: 5,
-		outputs:      []Output{&basicOutput{writer: os.Stdout, formatter: NewHumanReadableFormatter()}},
+		outputs:      []Output{&basicOutput{writer: os.Stdout, formatter: NewHumanReadableFormatter().WithAutoColors(os.Stdout)}},
 	}
 
 	return logger
@@ -58,7 +72,10 @@ func (b *basicLogger) getCaller(level int) (function string, source string) {
 	}
 
 	file, line := fun.FileLine(fpcs[0] - 1)
-	fName := fun.Name()
+	return formatCallSite(fun.Name(), file, line)
+}
+
+func formatCallSite(fName string, file string, line int) (function string, source string) {
 	lastSlashOfName := strings.LastIndex(fName, "/")
 	if lastSlashOfName > 0 {
 		fName = fName[lastSlashOfName+1:]
@@ -76,7 +93,7 @@ func (b *basicLogger) WithTags(params ...*Field) Logger {
 	copy(newTags, b.tags)
 	newTags = append(newTags, params...)
 	//prefixes := append(b.tags, params...)
-	return &basicLogger{tags: newTags, nestingLevel: b.nestingLevel, outputs: b.outputs, filters: b.filters}
+	return &basicLogger{tags: newTags, nestingLevel: b.nestingLevel, outputs: b.outputs, filters: b.filters, redactors: b.redactors}
 }
 
 func (b *basicLogger) Metric(params ...*Field) {
@@ -85,14 +102,16 @@ func (b *basicLogger) Metric(params ...*Field) {
 
 func (b *basicLogger) log(logLoggingErrors bool, level string, message string, params ...*Field) {
 	function, source := b.getCaller(b.nestingLevel)
+	b.logAt(logLoggingErrors, function, source, level, message, params...)
+}
 
-	enrichmentParams := flattenParams(
+// logAt logs a row with a call site that was found by the caller, see LineWriter
+func (b *basicLogger) logAt(logLoggingErrors bool, function string, source string, level string, message string, params ...*Field) {
+	enrichmentParams := append(
 		append(
-			append(
-				[]*Field{Function(function), Source(source)},
-				b.tags...),
-			params...),
-	)
+			[]*Field{Function(function), Source(source)},
+			b.tags...),
+		params...)
 
 	for _, f := range b.filters {
 		if !f.Allows(level, message, enrichmentParams) {
@@ -100,8 +119,20 @@ func (b *basicLogger) log(logLoggingErrors bool, level string, message string, p
 		}
 	}
 
+	for _, r := range b.redactors {
+		enrichmentParams = r.Redact(enrichmentParams)
+	}
+
+	var flattened []*Field
 	for _, output := range b.outputs {
-		b.appendTo(output, level, message, enrichmentParams, logLoggingErrors)
+		params := enrichmentParams
+		if !formatsAggregates(output) {
+			if flattened == nil {
+				flattened = flattenParams(enrichmentParams)
+			}
+			params = flattened
+		}
+		b.appendTo(output, level, message, params, logLoggingErrors)
 	}
 }
 
@@ -109,12 +140,60 @@ func (b *basicLogger) Log(level string, message string, params ...*Field) {
 	b.log(true, level, message, params...)
 }
 
+func (b *basicLogger) Debug(message string, params ...*Field) {
+	b.Log(DebugLevel.String(), message, params...)
+}
+
 func (b *basicLogger) Info(message string, params ...*Field) {
-	b.Log("info", message, params...)
+	b.Log(InfoLevel.String(), message, params...)
+}
+
+func (b *basicLogger) Warn(message string, params ...*Field) {
+	b.Log(WarnLevel.String(), message, params...)
 }
 
 func (b *basicLogger) Error(message string, params ...*Field) {
-	b.Log("error", message, params...)
+	b.Log(ErrorLevel.String(), message, params...)
+}
+
+// Fatal only logs the row at fatal level, it is up to the caller to terminate the process
+func (b *basicLogger) Fatal(message string, params ...*Field) {
+	b.Log(FatalLevel.String(), message, params...)
+}
+
+// LogCtx adds the current span (see TraceFromContext) and the tags stored on ctx by ContextWithTags between the logger's tags and params
+func (b *basicLogger) LogCtx(ctx context.Context, level string, message string, params ...*Field) {
+	var contextParams []*Field
+	if tc, ok := TraceFromContext(ctx); ok {
+		contextParams = TraceFields(tc)
+	}
+	contextParams = append(contextParams, TagsFromContext(ctx)...)
+
+	if len(contextParams) > 0 {
+		params = append(contextParams, params...)
+	}
+	b.log(true, level, message, params...)
+}
+
+func (b *basicLogger) DebugCtx(ctx context.Context, message string, params ...*Field) {
+	b.LogCtx(ctx, DebugLevel.String(), message, params...)
+}
+
+func (b *basicLogger) InfoCtx(ctx context.Context, message string, params ...*Field) {
+	b.LogCtx(ctx, InfoLevel.String(), message, params...)
+}
+
+func (b *basicLogger) WarnCtx(ctx context.Context, message string, params ...*Field) {
+	b.LogCtx(ctx, WarnLevel.String(), message, params...)
+}
+
+func (b *basicLogger) ErrorCtx(ctx context.Context, message string, params ...*Field) {
+	b.LogCtx(ctx, ErrorLevel.String(), message, params...)
+}
+
+// FatalCtx only logs the row at fatal level, it is up to the caller to terminate the process
+func (b *basicLogger) FatalCtx(ctx context.Context, message string, params ...*Field) {
+	b.LogCtx(ctx, FatalLevel.String(), message, params...)
 }
 
 func (b *basicLogger) WithOutput(writers ...Output) Logger {
@@ -122,6 +201,10 @@ func (b *basicLogger) WithOutput(writers ...Output) Logger {
 	return b
 }
 
+func (b *basicLogger) Outputs() []Output {
+	return b.outputs
+}
+
 func (b *basicLogger) WithFilters(filter ...Filter) Logger {
 	b.filters = append(b.filters, filter...) // this is not thread safe, I know
 	return b
@@ -131,6 +214,12 @@ func (b *basicLogger) Filters() []Filter {
 	return b.filters
 }
 
+// WithRedactor redacts fields of rows that filters allow, before they reach any output
+func (b *basicLogger) WithRedactor(redactor ...Redactor) Logger {
+	b.redactors = append(b.redactors, redactor...)
+	return b
+}
+
 func flattenParams(params []*Field) []*Field {
 	var flattened []*Field
 	for _, param := range params {
@@ -157,19 +246,20 @@ func (b *basicLogger) appendTo(output Output, level string, message string, enri
 	}
 
 	defer func() {
-		recoveredError := recover()
-		if recoveredError != nil {
-			var err error
-			switch errObj := recoveredError.(type) {
-			case error:
-				err = errObj
-			case string:
-				err = errors.New(errObj)
-			default:
-				err = errors.New("unknown error object type")
-			}
-			onError(err)
+		if recoveredError := recover(); recoveredError != nil {
+			onError(panicToError(recoveredError))
 		}
 	}()
 	output.Append(onError, level, message, enrichmentParams...)
 }
+
+func panicToError(recoveredError interface{}) error {
+	switch errObj := recoveredError.(type) {
+	case error:
+		return errObj
+	case string:
+		return errors.New(errObj)
+	default:
+		return errors.New("unknown error object type")
+	}
+}
@@ -88,6 +88,44 @@ func TestSimpleLogger_AggregateField_NestedLogger(t *testing.T) {
 	require.Equal(t, "Passenger", jsonMap["Song"])
 }
 
+func TestSimpleLogger_AggregateField_NestedJson(t *testing.T) {
+	b := new(bytes.Buffer)
+	GetLogger().
+		WithOutput(NewFormattingOutput(b, NewJsonFormatter().WithNestedAggregates())).
+		Info("bar", Aggregate("artist", String("id", "iggy")), Aggregate("song", String("id", "passenger"), Int("year", 1977)))
+
+	jsonMap := parseOutput(b.String())
+
+	require.Equal(t, map[string]interface{}{"id": "iggy"}, jsonMap["artist"])
+	require.Equal(t, map[string]interface{}{"id": "passenger", "year": 1977.0}, jsonMap["song"])
+	require.Nil(t, jsonMap["id"])
+}
+
+func TestBasicLogger_FlattensAggregatesForOutputsThatDoNotFormatThem(t *testing.T) {
+	custom := &recordingOutput{}
+	nested := new(bytes.Buffer)
+	GetLogger().
+		WithOutput(custom, NewFormattingOutput(nested, NewJsonFormatter().WithNestedAggregates())).
+		Info("bar", Aggregate("music", String("Artist", "Iggy Pop")))
+
+	require.Len(t, custom.fields, 3)
+	require.Equal(t, "Artist", custom.fields[2].Key, "a custom output should get aggregates flattened")
+	require.Equal(t, map[string]interface{}{"Artist": "Iggy Pop"}, parseOutput(nested.String())["music"])
+}
+
+func TestBasicLogger_FiltersMatchFieldsInsideAggregates(t *testing.T) {
+	b := new(bytes.Buffer)
+	logger := GetLogger().
+		WithOutput(NewFormattingOutput(b, NewJsonFormatter())).
+		WithFilters(MatchField(String("Artist", "Iggy Pop")))
+
+	logger.Info("foo", Aggregate("music", String("Artist", "David Bowie")))
+	require.Empty(t, b.String())
+
+	logger.Info("bar", Aggregate("music", String("Artist", "Iggy Pop")))
+	require.Equal(t, "Iggy Pop", parseOutput(b.String())["Artist"])
+}
+
 func TestBasicLogger_WithFilter(t *testing.T) {
 	b := new(bytes.Buffer)
 	GetLogger().WithOutput(NewFormattingOutput(b, NewJsonFormatter())).
@@ -96,6 +134,56 @@ func TestBasicLogger_WithFilter(t *testing.T) {
 	require.Empty(t, b.String(), "output was not empty")
 }
 
+func TestBasicLogger_LevelMethods(t *testing.T) {
+	b := new(bytes.Buffer)
+	logger := GetLogger().WithOutput(NewFormattingOutput(b, NewJsonFormatter()))
+
+	for _, level := range []Level{DebugLevel, InfoLevel, WarnLevel, ErrorLevel, FatalLevel} {
+		b.Reset()
+		switch level {
+		case DebugLevel:
+			logger.Debug("foo")
+		case InfoLevel:
+			logger.Info("foo")
+		case WarnLevel:
+			logger.Warn("foo")
+		case ErrorLevel:
+			logger.Error("foo")
+		case FatalLevel:
+			logger.Fatal("foo")
+		}
+		require.Equal(t, level.String(), parseOutput(b.String())["level"])
+	}
+}
+
+func TestBasicLogger_WithMinLevelFilter(t *testing.T) {
+	b := new(bytes.Buffer)
+	logger := GetLogger().WithOutput(NewFormattingOutput(b, NewJsonFormatter())).
+		WithFilters(MinLevel(WarnLevel))
+
+	logger.Info("foo")
+	require.Empty(t, b.String(), "output was not empty")
+
+	logger.Warn("bar")
+	require.Regexp(t, "bar", b.String())
+}
+
+func TestOutput_SetMinLevel(t *testing.T) {
+	b1 := new(bytes.Buffer)
+	b2 := new(bytes.Buffer)
+	o1 := NewFormattingOutput(b1, NewJsonFormatter())
+	o2 := NewFormattingOutput(b2, NewJsonFormatter())
+	o2.SetMinLevel(ErrorLevel)
+
+	logger := GetLogger().WithOutput(o1, o2)
+	logger.Debug("foo")
+	logger.Log("metric", "bar")
+
+	require.Regexp(t, "foo", b1.String())
+	require.NotRegexp(t, "foo", b2.String())
+	require.Regexp(t, "bar", b2.String(), "metric rows should not be subject to level thresholds")
+}
+
 func TestNestedLogger(t *testing.T) {
 	b := new(bytes.Buffer)
 
@@ -158,8 +246,8 @@ func TestHumanReadable_AggregateField(t *testing.T) {
 		Info("bar", aggregatedFields)
 
 	out := b.String()
-	require.Regexp(t, "Artist=Iggy Pop", out)
-	require.Regexp(t, "Song=Passenger", out)
+	require.Regexp(t, "music.Artist=Iggy Pop", out)
+	require.Regexp(t, "music.Song=Passenger", out)
 
 }
 
@@ -335,6 +423,17 @@ func (p *panickingOutput) Append(onError func(err error), level string, message
 	panic(p.errObj)
 }
 
+type recordingOutput struct {
+	fields []*Field
+}
+
+func (o *recordingOutput) SetFilters(_ ...Filter) {
+}
+
+func (o *recordingOutput) Append(onError func(err error), level string, message string, fields ...*Field) {
+	o.fields = fields
+}
+
 type erroneousOutput struct {
 	err error
 }
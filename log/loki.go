@@ -0,0 +1,262 @@
+// Copyright 2019 the orbs-network-go authors
+// This file is part of the orbs-network-go library in the Orbs project.
+//
+// This source code is licensed under the MIT license found in the LICENSE file in the root directory of this source tree.
+// The above notice should be included in all copies or substantial portions of the software.
+
+package log
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/pkg/errors"
+)
+
+// a label key that takes its value from the row's level rather than from a field
+const LOKI_LEVEL_LABEL = "level"
+
+// every stream has this label, as Loki rejects streams without labels; it defaults to the name of the executable, see WithStaticLabel
+const LOKI_JOB_LABEL = "job"
+
+type LokiEncoding int
+
+const (
+	// LokiEncodingProtobuf sends snappy compressed protobuf push requests, which is what Loki's own clients send
+	LokiEncodingProtobuf LokiEncoding = iota
+	LokiEncodingJSON
+)
+
+type lokiFormatter struct {
+	labelKeys    []string
+	staticLabels map[string]string
+	line         LogFormatter
+}
+
+// the formatter hands rows to lokiWriter in this form, one per line; the writer groups them into streams
+type lokiRow struct {
+	Labels    map[string]string `json:"labels"`
+	Timestamp int64             `json:"ts"`
+	Line      string            `json:"line"`
+}
+
+// NewLokiFormatter groups rows into streams by the fields with labelKeys (and by level, with LOKI_LEVEL_LABEL),
+// and formats the other fields as the line, in logfmt unless WithLineFormatter says otherwise.
+// meant to be used with NewBulkOutput and NewLokiWriter, see NewLokiOutput
+func NewLokiFormatter(labelKeys ...string) *lokiFormatter {
+	return &lokiFormatter{
+		labelKeys:    labelKeys,
+		staticLabels: map[string]string{LOKI_JOB_LABEL: filepath.Base(os.Args[0])},
+		line:         NewLogfmtFormatter(),
+	}
+}
+
+// WithStaticLabel gives every stream the label key with value, unless a field of the row gives it another value
+func (f *lokiFormatter) WithStaticLabel(key string, value string) *lokiFormatter {
+	f.staticLabels[sanitizeMetricName(key, false)] = value
+	return f
+}
+
+func (f *lokiFormatter) WithLineFormatter(line LogFormatter) *lokiFormatter {
+	f.line = line
+	return f
+}
+
+func (f *lokiFormatter) FormatRow(timestamp time.Time, level string, message string, params ...*Field) (formattedRow string) {
+	row := &lokiRow{Labels: make(map[string]string, len(f.staticLabels)+len(f.labelKeys)), Timestamp: timestamp.UnixNano()}
+	for key, value := range f.staticLabels {
+		row.Labels[key] = value
+	}
+
+	var lineParams []*Field
+	for _, param := range params {
+		if param != nil && !param.IsNested() && containsString(f.labelKeys, param.Key) {
+			row.Labels[sanitizeMetricName(param.Key, false)] = logfmtValue(param)
+		} else {
+			lineParams = append(lineParams, param)
+		}
+	}
+
+	if containsString(f.labelKeys, LOKI_LEVEL_LABEL) {
+		row.Labels[LOKI_LEVEL_LABEL] = level
+	}
+
+	row.Line = f.line.FormatRow(timestamp, level, message, lineParams...)
+
+	formatted, err := json.Marshal(row)
+	if err != nil {
+		return ""
+	}
+
+	return string(formatted)
+}
+
+type lokiWriter struct {
+	http     *httpWriter
+	encoding LokiEncoding
+}
+
+// NewLokiWriter pushes bulks of rows formatted by NewLokiFormatter to the /loki/api/v1/push endpoint that writer posts to
+func NewLokiWriter(writer *httpWriter, encoding LokiEncoding) *lokiWriter {
+	if encoding == LokiEncodingJSON {
+		writer.WithContentType("application/json")
+	} else {
+		writer.WithContentType("application/x-protobuf")
+	}
+
+	return &lokiWriter{http: writer, encoding: encoding}
+}
+
+// NewLokiOutput batches rows like NewBulkOutputWithOptions does, and pushes them to the Loki at baseURL
+func NewLokiOutput(baseURL string, encoding LokiEncoding, formatter *lokiFormatter, opts BulkOutputOptions) BulkOutput {
+	writer := NewLokiWriter(NewHttpWriter(strings.TrimSuffix(baseURL, "/")+"/loki/api/v1/push"), encoding)
+	return NewBulkOutputWithOptions(writer, formatter, opts)
+}
+
+type lokiStream struct {
+	labels  map[string]string
+	key     string
+	entries []*lokiRow
+}
+
+func (w *lokiWriter) Write(p []byte) (n int, err error) {
+	streams, err := groupLokiStreams(p)
+	if err != nil {
+		return 0, err
+	}
+
+	var body []byte
+	if w.encoding == LokiEncodingJSON {
+		body, err = encodeLokiJSON(streams)
+		if err != nil {
+			return 0, err
+		}
+	} else {
+		body = snappy.Encode(nil, encodeLokiProtobuf(streams))
+	}
+
+	if _, err := w.http.Write(body); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+// streams are sorted by labels and their entries by time, as older Loki versions reject out of order entries
+func groupLokiStreams(p []byte) ([]*lokiStream, error) {
+	byKey := make(map[string]*lokiStream)
+	var streams []*lokiStream
+
+	for _, line := range bytes.Split(bytes.TrimRight(p, "\n"), []byte("\n")) {
+		row := &lokiRow{}
+		if err := json.Unmarshal(line, row); err != nil {
+			return nil, errors.Errorf("failed to parse row, was it formatted by NewLokiFormatter? %s", err)
+		}
+
+		key := lokiLabelString(row.Labels)
+		stream, ok := byKey[key]
+		if !ok {
+			stream = &lokiStream{labels: row.Labels, key: key}
+			byKey[key] = stream
+			streams = append(streams, stream)
+		}
+		stream.entries = append(stream.entries, row)
+	}
+
+	sort.Slice(streams, func(i, j int) bool {
+		return streams[i].key < streams[j].key
+	})
+	for _, stream := range streams {
+		entries := stream.entries
+		sort.SliceStable(entries, func(i, j int) bool {
+			return entries[i].Timestamp < entries[j].Timestamp
+		})
+	}
+
+	return streams, nil
+}
+
+// formats labels the way Loki's protobuf push requests carry them: {key="value", ...} sorted by key
+func lokiLabelString(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for key := range labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		pairs = append(pairs, key+"="+strconv.Quote(labels[key]))
+	}
+
+	return "{" + strings.Join(pairs, ", ") + "}"
+}
+
+func encodeLokiJSON(streams []*lokiStream) ([]byte, error) {
+	type jsonStream struct {
+		Stream map[string]string `json:"stream"`
+		Values [][2]string       `json:"values"`
+	}
+
+	request := struct {
+		Streams []jsonStream `json:"streams"`
+	}{}
+
+	for _, stream := range streams {
+		s := jsonStream{Stream: stream.labels}
+		for _, entry := range stream.entries {
+			s.Values = append(s.Values, [2]string{strconv.FormatInt(entry.Timestamp, 10), entry.Line})
+		}
+		request.Streams = append(request.Streams, s)
+	}
+
+	return json.Marshal(request)
+}
+
+// PushRequest{streams: [StreamAdapter{labels, entries: [EntryAdapter{timestamp: Timestamp{seconds, nanos}, line}]}]}, see Loki's logproto
+func encodeLokiProtobuf(streams []*lokiStream) []byte {
+	var request []byte
+	for _, stream := range streams {
+		streamAdapter := appendProtobufBytes(nil, 1, []byte(stream.key))
+		for _, entry := range stream.entries {
+			timestamp := appendProtobufVarint(nil, 1, uint64(entry.Timestamp/int64(time.Second)))
+			timestamp = appendProtobufVarint(timestamp, 2, uint64(entry.Timestamp%int64(time.Second)))
+
+			entryAdapter := appendProtobufBytes(nil, 1, timestamp)
+			entryAdapter = appendProtobufBytes(entryAdapter, 2, []byte(entry.Line))
+
+			streamAdapter = appendProtobufBytes(streamAdapter, 2, entryAdapter)
+		}
+		request = appendProtobufBytes(request, 1, streamAdapter)
+	}
+
+	return request
+}
+
+func appendProtobufVarint(dst []byte, field int, value uint64) []byte {
+	if value == 0 {
+		return dst // the default value is left out
+	}
+
+	dst = appendUvarint(dst, uint64(field<<3))
+	return appendUvarint(dst, value)
+}
+
+func appendProtobufBytes(dst []byte, field int, value []byte) []byte {
+	dst = appendUvarint(dst, uint64(field<<3|2))
+	dst = appendUvarint(dst, uint64(len(value)))
+	return append(dst, value...)
+}
+
+func appendUvarint(dst []byte, value uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	return append(dst, buf[:binary.PutUvarint(buf[:], value)]...)
+}
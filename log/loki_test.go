@@ -0,0 +1,158 @@
+// Copyright 2019 the orbs-network-go authors
+// This file is part of the orbs-network-go library in the Orbs project.
+//
+// This source code is licensed under the MIT license found in the LICENSE file in the root directory of this source tree.
+// The above notice should be included in all copies or substantial portions of the software.
+
+package log
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/stretchr/testify/require"
+)
+
+// returns the fields of a protobuf message by number, as raw bytes for length delimited fields and as uvarints otherwise
+func decodeProtobuf(t *testing.T, message []byte) map[int][]interface{} {
+	fields := make(map[int][]interface{})
+	for len(message) > 0 {
+		key, n := binary.Uvarint(message)
+		require.True(t, n > 0)
+		message = message[n:]
+
+		value, n := binary.Uvarint(message)
+		require.True(t, n > 0)
+		message = message[n:]
+
+		if key&7 == 2 {
+			fields[int(key>>3)] = append(fields[int(key>>3)], message[:value])
+			message = message[value:]
+		} else {
+			fields[int(key>>3)] = append(fields[int(key>>3)], value)
+		}
+	}
+
+	return fields
+}
+
+func newLokiServer() (*httptest.Server, func() []*http.Request, func() [][]byte) {
+	var lock sync.Mutex
+	var requests []*http.Request
+	var bodies [][]byte
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+
+		lock.Lock()
+		requests = append(requests, r)
+		bodies = append(bodies, body)
+		lock.Unlock()
+
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	return server, func() []*http.Request {
+			lock.Lock()
+			defer lock.Unlock()
+			return requests
+		}, func() [][]byte {
+			lock.Lock()
+			defer lock.Unlock()
+			return bodies
+		}
+}
+
+func TestLokiFormatter_SplitsLabelsFromLine(t *testing.T) {
+	tm := time.Unix(1, 5)
+	row := NewLokiFormatter("node", LOKI_LEVEL_LABEL).WithStaticLabel("job", "scribe").FormatRow(tm, "info", "foo", Node("node1"), Int("vcid", 42))
+
+	parsed := &lokiRow{}
+	require.NoError(t, json.Unmarshal([]byte(row), parsed))
+	require.Equal(t, map[string]string{"job": "scribe", "node": "node1", "level": "info"}, parsed.Labels)
+	require.EqualValues(t, 1000000005, parsed.Timestamp)
+	require.Equal(t, "level=info ts=1970-01-01T00:00:01.000000005Z msg=foo vcid=42", parsed.Line)
+}
+
+func TestLokiFormatter_AlwaysLabelsStreams(t *testing.T) {
+	parse := func(row string) map[string]string {
+		parsed := &lokiRow{}
+		require.NoError(t, json.Unmarshal([]byte(row), parsed))
+		return parsed.Labels
+	}
+
+	require.Equal(t, map[string]string{"job": filepath.Base(os.Args[0])}, parse(NewLokiFormatter().FormatRow(time.Now(), "info", "foo")), "the job should default to the executable")
+	require.Equal(t, map[string]string{"job": "node1"}, parse(NewLokiFormatter("job").FormatRow(time.Now(), "info", "foo", String("job", "node1"))))
+}
+
+func TestLokiOutput_PushesJSON(t *testing.T) {
+	server, requests, bodies := newLokiServer()
+	defer server.Close()
+
+	output := NewLokiOutput(server.URL, LokiEncodingJSON, NewLokiFormatter("node").WithStaticLabel("job", "scribe").WithLineFormatter(NewJsonFormatter()), BulkOutputOptions{BulkSize: 3})
+	GetLogger(Node("node1")).WithOutput(output).Info("foo")
+	GetLogger(Node("node2")).WithOutput(output).Info("bar")
+	GetLogger(Node("node1")).WithOutput(output).Info("baz")
+	require.NoError(t, output.Flush())
+
+	require.Len(t, requests(), 1)
+	require.Equal(t, "/loki/api/v1/push", requests()[0].URL.Path)
+	require.Equal(t, "application/json", requests()[0].Header.Get("Content-Type"))
+
+	var pushed struct {
+		Streams []struct {
+			Stream map[string]string `json:"stream"`
+			Values [][2]string       `json:"values"`
+		} `json:"streams"`
+	}
+	require.NoError(t, json.Unmarshal(bodies()[0], &pushed))
+	require.Len(t, pushed.Streams, 2)
+	require.Equal(t, map[string]string{"job": "scribe", "node": "node1"}, pushed.Streams[0].Stream)
+	require.Len(t, pushed.Streams[0].Values, 2)
+	require.Equal(t, "foo", parseOutput(pushed.Streams[0].Values[0][1])["message"])
+	require.Equal(t, "baz", parseOutput(pushed.Streams[0].Values[1][1])["message"])
+	require.Equal(t, map[string]string{"job": "scribe", "node": "node2"}, pushed.Streams[1].Stream)
+	require.Regexp(t, `^\d{19}$`, pushed.Streams[1].Values[0][0])
+}
+
+func TestLokiWriter_PushesSnappyProtobuf(t *testing.T) {
+	server, requests, bodies := newLokiServer()
+	defer server.Close()
+
+	f := NewLokiFormatter("node").WithStaticLabel("job", "scribe")
+	payload := f.FormatRow(time.Unix(2, 7), "info", "bar", Node("node1")) + "\n" +
+		f.FormatRow(time.Unix(1, 5), "info", "foo", Node("node1")) + "\n"
+
+	_, err := NewLokiWriter(NewHttpWriter(server.URL), LokiEncodingProtobuf).Write([]byte(payload))
+	require.NoError(t, err)
+	require.Equal(t, "application/x-protobuf", requests()[0].Header.Get("Content-Type"))
+
+	decoded, err := snappy.Decode(nil, bodies()[0])
+	require.NoError(t, err)
+	request := decodeProtobuf(t, decoded)
+	require.Len(t, request[1], 1)
+
+	stream := decodeProtobuf(t, request[1][0].([]byte))
+	require.Equal(t, `{job="scribe", node="node1"}`, string(stream[1][0].([]byte)))
+	require.Len(t, stream[2], 2)
+
+	first := decodeProtobuf(t, stream[2][0].([]byte))
+	timestamp := decodeProtobuf(t, first[1][0].([]byte))
+	require.EqualValues(t, 1, timestamp[1][0])
+	require.EqualValues(t, 5, timestamp[2][0])
+	require.Regexp(t, "msg=foo", string(first[2][0].([]byte)), "entries should be sorted by time")
+}
+
+func TestLokiLabelString(t *testing.T) {
+	require.Equal(t, `{level="info", node="a \"quoted\" name"}`, lokiLabelString(map[string]string{"node": `a "quoted" name`, "level": "info"}))
+	require.Equal(t, "{}", lokiLabelString(nil))
+}
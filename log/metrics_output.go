@@ -0,0 +1,366 @@
+// Copyright 2019 the orbs-network-go authors
+// This file is part of the orbs-network-go library in the Orbs project.
+//
+// This source code is licensed under the MIT license found in the LICENSE file in the root directory of this source tree.
+// The above notice should be included in all copies or substantial portions of the software.
+
+package log
+
+import (
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// fields of metric rows (see Logger.Metric) that MetricsOutput interprets; Node and Service fields, and fields allowed
+// with MetricsOutputOptions.LabelKeys, become labels
+const (
+	METRIC_NAME_KEY  = "metric-name"
+	METRIC_VALUE_KEY = "metric-value"
+	METRIC_KIND_KEY  = "metric-kind"
+)
+
+type MetricKind string
+
+const (
+	MetricKindCounter   MetricKind = "counter"
+	MetricKindGauge     MetricKind = "gauge"
+	MetricKindHistogram MetricKind = "histogram"
+)
+
+var DEFAULT_HISTOGRAM_BUCKETS = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Counter returns the fields of a metric row that adds value to the counter name, for example:
+//
+//	logger.Metric(append(log.Counter("blocks_committed_total", 1), log.String("shard", "3"))...)
+//
+// with "shard" in MetricsOutputOptions.LabelKeys
+func Counter(name string, value float64) []*Field {
+	return metricFields(MetricKindCounter, name, value)
+}
+
+// Gauge returns the fields of a metric row that sets the gauge name to value
+func Gauge(name string, value float64) []*Field {
+	return metricFields(MetricKindGauge, name, value)
+}
+
+// Histogram returns the fields of a metric row that observes value in the histogram name
+func Histogram(name string, value float64) []*Field {
+	return metricFields(MetricKindHistogram, name, value)
+}
+
+func metricFields(kind MetricKind, name string, value float64) []*Field {
+	return []*Field{String(METRIC_NAME_KEY, name), Float64(METRIC_VALUE_KEY, value), String(METRIC_KIND_KEY, string(kind))}
+}
+
+type MetricsOutputOptions struct {
+	// prepended to every metric name, separated by an underscore
+	Namespace string
+	// upper bounds of histogram buckets, DEFAULT_HISTOGRAM_BUCKETS if empty
+	Buckets []float64
+	// keys of string and integer fields that become labels, in addition to Node and Service fields;
+	// any other field would give rows with ids or timestamps a series of their own
+	LabelKeys []string
+}
+
+// prometheus uses these labels for histogram buckets and summary quantiles, so fields with these keys are exported with an exported_ prefix
+var reservedLabels = map[string]bool{"le": true, "quantile": true}
+
+type metricsOutput struct {
+	namespace string
+	buckets   []float64
+	labelKeys []string
+	filters   and
+
+	lock     sync.Mutex
+	families map[string]*metricFamily
+}
+
+type metricFamily struct {
+	kind   MetricKind
+	series map[string]*metricSeries // by formatted labels
+}
+
+type metricSeries struct {
+	value   float64
+	buckets []uint64 // not cumulative, the last one counts values above all bounds
+	sum     float64
+	count   uint64
+}
+
+// NewMetricsOutput aggregates metric rows in memory and serves them in Prometheus text exposition format.
+// rows of other levels are ignored, so it is meant to be added next to the logger's other outputs
+func NewMetricsOutput(opts MetricsOutputOptions) *metricsOutput {
+	buckets := opts.Buckets
+	if len(buckets) == 0 {
+		buckets = DEFAULT_HISTOGRAM_BUCKETS
+	}
+
+	sorted := make([]float64, len(buckets))
+	copy(sorted, buckets)
+	sort.Float64s(sorted)
+
+	return &metricsOutput{
+		namespace: opts.Namespace,
+		buckets:   sorted,
+		labelKeys: opts.LabelKeys,
+		families:  make(map[string]*metricFamily),
+	}
+}
+
+func (out *metricsOutput) SetFilters(filters ...Filter) {
+	out.filters = and{filters}
+}
+
+func (out *metricsOutput) Append(onError func(err error), level string, message string, fields ...*Field) {
+	if level != "metric" || !out.filters.Allows(level, message, fields) {
+		return
+	}
+
+	var name, kind *Field
+	var value *Field
+	var labels []*Field
+	visitFields(fields, func(f *Field) bool {
+		switch f.Key {
+		case METRIC_NAME_KEY:
+			name = f
+		case METRIC_KIND_KEY:
+			kind = f
+		case METRIC_VALUE_KEY:
+			value = f
+		default:
+			if isLabelField(f, out.labelKeys) {
+				labels = append(labels, f)
+			}
+		}
+		return true
+	})
+
+	if name == nil {
+		return // a metric row that does not follow the convention, such as the dropped rows count of asyncOutput
+	}
+
+	v, ok := metricValue(value)
+	if !ok {
+		onError(errors.Errorf("metric %s has no numeric %s field", name.StringVal, METRIC_VALUE_KEY))
+		return
+	}
+
+	k := MetricKindGauge
+	if kind != nil {
+		k = MetricKind(kind.StringVal)
+	}
+
+	if err := out.record(out.metricName(name.StringVal), k, formatLabels(labels), v); err != nil {
+		onError(err)
+	}
+}
+
+func isLabelField(f *Field, labelKeys []string) bool {
+	switch f.Type {
+	case NodeType, ServiceType:
+		return true
+	case StringType, IntType, UintType:
+		return containsString(labelKeys, f.Key)
+	}
+
+	return false
+}
+
+func metricValue(f *Field) (float64, bool) {
+	if f == nil {
+		return 0, false
+	}
+
+	switch f.Type {
+	case FloatType:
+		return f.Float, true
+	case IntType:
+		return float64(f.Int), true
+	case UintType:
+		return float64(f.Uint), true
+	}
+
+	return 0, false
+}
+
+func (out *metricsOutput) record(name string, kind MetricKind, labels string, value float64) error {
+	out.lock.Lock()
+	defer out.lock.Unlock()
+
+	family, ok := out.families[name]
+	if !ok {
+		switch kind {
+		case MetricKindCounter, MetricKindGauge, MetricKindHistogram:
+		default:
+			return errors.Errorf("metric %s has unknown kind %s", name, kind)
+		}
+		family = &metricFamily{kind: kind, series: make(map[string]*metricSeries)}
+		out.families[name] = family
+	} else if family.kind != kind {
+		return errors.Errorf("metric %s was recorded as a %s, and can't be recorded as a %s", name, family.kind, kind)
+	}
+
+	if kind == MetricKindCounter && value < 0 {
+		return errors.Errorf("counter %s can't be decreased by %v", name, value)
+	}
+
+	series, ok := family.series[labels]
+	if !ok {
+		series = &metricSeries{}
+		if kind == MetricKindHistogram {
+			series.buckets = make([]uint64, len(out.buckets)+1)
+		}
+		family.series[labels] = series
+	}
+
+	switch kind {
+	case MetricKindCounter:
+		series.value += value
+	case MetricKindGauge:
+		series.value = value
+	case MetricKindHistogram:
+		series.buckets[sort.SearchFloat64s(out.buckets, value)]++
+		series.sum += value
+		series.count++
+	}
+
+	return nil
+}
+
+func (out *metricsOutput) metricName(name string) string {
+	if out.namespace != "" {
+		name = out.namespace + "_" + name
+	}
+
+	return sanitizeMetricName(name, true)
+}
+
+// prometheus names are [a-zA-Z_][a-zA-Z0-9_]*, and metric names may also contain colons
+func sanitizeMetricName(name string, allowColons bool) string {
+	b := []byte(name)
+	for i, c := range b {
+		valid := c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (i > 0 && c >= '0' && c <= '9') || (allowColons && c == ':')
+		if !valid {
+			b[i] = '_'
+		}
+	}
+
+	return string(b)
+}
+
+// labels are sorted by name so that the same set of fields always maps to the same series.
+// of fields whose keys map to the same label name, the last one wins, as fields logged with a row come after the logger's tags
+func formatLabels(labels []*Field) string {
+	if len(labels) == 0 {
+		return ""
+	}
+
+	values := make(map[string]string, len(labels))
+	for _, f := range labels {
+		name := sanitizeMetricName(f.Key, false)
+		if reservedLabels[name] {
+			name = "exported_" + name
+		}
+		values[name] = logfmtValue(f)
+	}
+
+	pairs := make([]string, 0, len(values))
+	for name, value := range values {
+		pairs = append(pairs, name+`="`+escapeLabelValue(value)+`"`)
+	}
+	sort.Strings(pairs)
+
+	return strings.Join(pairs, ",")
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabelValue(value string) string {
+	return labelValueEscaper.Replace(value)
+}
+
+func formatMetricValue(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	}
+
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+func (out *metricsOutput) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_, _ = w.Write([]byte(out.Exposition()))
+}
+
+// Exposition formats everything recorded so far in Prometheus text exposition format
+func (out *metricsOutput) Exposition() string {
+	out.lock.Lock()
+	defer out.lock.Unlock()
+
+	names := make([]string, 0, len(out.families))
+	for name := range out.families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var sb strings.Builder
+	for _, name := range names {
+		family := out.families[name]
+		sb.WriteString("# TYPE " + name + " " + string(family.kind) + "\n")
+
+		labelSets := make([]string, 0, len(family.series))
+		for labels := range family.series {
+			labelSets = append(labelSets, labels)
+		}
+		sort.Strings(labelSets)
+
+		for _, labels := range labelSets {
+			series := family.series[labels]
+			if family.kind == MetricKindHistogram {
+				out.writeHistogram(&sb, name, labels, series)
+			} else {
+				writeSample(&sb, name, labels, series.value)
+			}
+		}
+	}
+
+	return sb.String()
+}
+
+// assumes lock
+func (out *metricsOutput) writeHistogram(sb *strings.Builder, name string, labels string, series *metricSeries) {
+	var cumulative uint64
+	for i, bound := range out.buckets {
+		cumulative += series.buckets[i]
+		writeSample(sb, name+"_bucket", joinLabels(labels, `le="`+formatMetricValue(bound)+`"`), float64(cumulative))
+	}
+	writeSample(sb, name+"_bucket", joinLabels(labels, `le="+Inf"`), float64(series.count))
+	writeSample(sb, name+"_sum", labels, series.sum)
+	writeSample(sb, name+"_count", labels, float64(series.count))
+}
+
+func joinLabels(labels string, label string) string {
+	if labels == "" {
+		return label
+	}
+	return labels + "," + label
+}
+
+func writeSample(sb *strings.Builder, name string, labels string, value float64) {
+	sb.WriteString(name)
+	if labels != "" {
+		sb.WriteString("{" + labels + "}")
+	}
+	sb.WriteString(" " + formatMetricValue(value) + "\n")
+}
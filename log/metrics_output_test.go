@@ -0,0 +1,111 @@
+// Copyright 2019 the orbs-network-go authors
+// This file is part of the orbs-network-go library in the Orbs project.
+//
+// This source code is licensed under the MIT license found in the LICENSE file in the root directory of this source tree.
+// The above notice should be included in all copies or substantial portions of the software.
+
+package log
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetricsOutput_AggregatesCountersAndGauges(t *testing.T) {
+	output := NewMetricsOutput(MetricsOutputOptions{Namespace: "orbs", LabelKeys: []string{"vcid"}})
+	logger := GetLogger(Node("node1"), Int("vcid", 42), String("request-id", "abc")).WithOutput(output)
+
+	logger.Metric(Counter("blocks-committed", 1)...)
+	logger.Metric(Counter("blocks-committed", 2)...)
+	logger.Metric(Gauge("peers", 5)...)
+	logger.Metric(Gauge("peers", 3)...)
+
+	require.Equal(t, `# TYPE orbs_blocks_committed counter
+orbs_blocks_committed{node="node1",vcid="42"} 3
+# TYPE orbs_peers gauge
+orbs_peers{node="node1",vcid="42"} 3
+`, output.Exposition())
+}
+
+func TestMetricsOutput_KeepsSeriesPerLabelSet(t *testing.T) {
+	output := NewMetricsOutput(MetricsOutputOptions{LabelKeys: []string{"method"}})
+	logger := GetLogger().WithOutput(output)
+
+	logger.Metric(append(Counter("requests", 1), String("method", "get"))...)
+	logger.Metric(append(Counter("requests", 1), String("method", `"post"`))...)
+	logger.Metric(append(Counter("requests", 1), String("method", "get"))...)
+
+	require.Equal(t, `# TYPE requests counter
+requests{method="\"post\""} 1
+requests{method="get"} 2
+`, output.Exposition())
+}
+
+func TestMetricsOutput_DeduplicatesAndReservesLabels(t *testing.T) {
+	output := NewMetricsOutput(MetricsOutputOptions{LabelKeys: []string{"shard", "le", "quantile"}})
+	logger := GetLogger(String("shard", "1")).WithOutput(output)
+
+	logger.Metric(append(Histogram("latency", 0.5), String("shard", "2"), String("le", "x"), Int("quantile", 1))...)
+
+	require.Contains(t, output.Exposition(), `latency_bucket{exported_le="x",exported_quantile="1",shard="2",le="1"} 1`)
+	require.Contains(t, output.Exposition(), `latency_count{exported_le="x",exported_quantile="1",shard="2"} 1`)
+}
+
+func TestMetricsOutput_Histogram(t *testing.T) {
+	output := NewMetricsOutput(MetricsOutputOptions{Buckets: []float64{1, 0.1}})
+	logger := GetLogger().WithOutput(output)
+
+	for _, v := range []float64{0.05, 0.1, 0.5, 2} {
+		logger.Metric(Histogram("latency", v)...)
+	}
+
+	require.Equal(t, `# TYPE latency histogram
+latency_bucket{le="0.1"} 2
+latency_bucket{le="1"} 3
+latency_bucket{le="+Inf"} 4
+latency_sum 2.65
+latency_count 4
+`, output.Exposition())
+}
+
+func TestMetricsOutput_IgnoresOtherRows(t *testing.T) {
+	output := NewMetricsOutput(MetricsOutputOptions{})
+	b := new(bytes.Buffer)
+	logger := GetLogger().WithOutput(output, NewFormattingOutput(b, nopFormatter{}))
+
+	logger.Info("foo", Counter("requests", 1)...)
+	logger.Metric(Uint64("async-output-dropped-rows", 3))
+	logger.Metric(Gauge("peers", 5)...)
+
+	require.Equal(t, "# TYPE peers gauge\npeers 5\n", output.Exposition())
+	require.Equal(t, "foo\nMetric recorded\nMetric recorded\n", b.String(), "rows should still reach the other outputs")
+}
+
+func TestMetricsOutput_ReportsConflictingKinds(t *testing.T) {
+	var errs []error
+	output := NewMetricsOutput(MetricsOutputOptions{})
+
+	output.Append(func(err error) { errs = append(errs, err) }, "metric", "", Counter("requests", 1)...)
+	output.Append(func(err error) { errs = append(errs, err) }, "metric", "", Gauge("requests", 1)...)
+	output.Append(func(err error) { errs = append(errs, err) }, "metric", "", Counter("requests", -1)...)
+	output.Append(func(err error) { errs = append(errs, err) }, "metric", "", String(METRIC_NAME_KEY, "foo"))
+
+	require.Len(t, errs, 3)
+	require.EqualError(t, errs[0], "metric requests was recorded as a counter, and can't be recorded as a gauge")
+}
+
+func TestMetricsOutput_ServesExposition(t *testing.T) {
+	output := NewMetricsOutput(MetricsOutputOptions{})
+	GetLogger().WithOutput(output).Metric(Gauge("peers", 5)...)
+
+	rec := httptest.NewRecorder()
+	output.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+	body, _ := ioutil.ReadAll(rec.Body)
+	require.Equal(t, "# TYPE peers gauge\npeers 5\n", string(body))
+	require.Regexp(t, "^text/plain; version=0.0.4", rec.Header().Get("Content-Type"))
+}
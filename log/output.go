@@ -6,7 +6,76 @@
 
 package log
 
+import (
+	"sync/atomic"
+	"time"
+)
+
 type Output interface {
 	Append(onError func(err error), level string, message string, fields ...*Field)
 	SetFilters(filter ...Filter)
 }
+
+// implemented by outputs that stamp rows themselves, so that wrappers that append later (such as asyncOutput) keep the time the row was logged
+type timestampedOutput interface {
+	appendAt(onError func(err error), timestamp time.Time, level string, message string, fields ...*Field)
+}
+
+// implemented by outputs that pass rows on to another output, so that it can be flushed too
+type wrappingOutput interface {
+	unwrap() Output
+}
+
+// implemented by outputs and formatters that handle Aggregate fields themselves; every other output gets aggregates
+// flattened into the row by the logger, so that custom outputs and formatters never see them
+type aggregateFormatter interface {
+	formatsAggregates() bool
+}
+
+func formatsAggregates(x interface{}) bool {
+	switch o := x.(type) {
+	case aggregateFormatter:
+		return o.formatsAggregates()
+	case wrappingOutput:
+		return formatsAggregates(o.unwrap())
+	}
+
+	return false
+}
+
+func appendAt(output Output, onError func(err error), timestamp time.Time, level string, message string, fields ...*Field) {
+	if o, ok := output.(timestampedOutput); ok {
+		o.appendAt(onError, timestamp, level, message, fields...)
+	} else {
+		output.Append(onError, level, message, fields...)
+	}
+}
+
+// LeveledOutput is an Output with a severity threshold of its own, independent of its filters
+type LeveledOutput interface {
+	Output
+	SetMinLevel(level Level)
+}
+
+// the threshold is kept as level+1 so that the zero value means no threshold, and is accessed atomically so it can be changed while logging
+type levelThreshold struct {
+	minLevel int32
+}
+
+func (t *levelThreshold) SetMinLevel(level Level) {
+	atomic.StoreInt32(&t.minLevel, int32(level)+1)
+}
+
+func (t *levelThreshold) threshold() (level Level, ok bool) {
+	min := atomic.LoadInt32(&t.minLevel)
+	return Level(min - 1), min != 0
+}
+
+func (t *levelThreshold) clearMinLevel() {
+	atomic.StoreInt32(&t.minLevel, 0)
+}
+
+func (t *levelThreshold) allows(level string) bool {
+	min := atomic.LoadInt32(&t.minLevel)
+	return min == 0 || isLevelAtLeast(level, Level(min-1))
+}
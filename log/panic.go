@@ -0,0 +1,114 @@
+// Copyright 2019 the orbs-network-go authors
+// This file is part of the orbs-network-go library in the Orbs project.
+//
+// This source code is licensed under the MIT license found in the LICENSE file in the root directory of this source tree.
+// The above notice should be included in all copies or substantial portions of the software.
+
+package log
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"time"
+)
+
+const DEFAULT_PANIC_FLUSH_TIMEOUT = 5 * time.Second
+
+const PANIC_MESSAGE = "recovered from panic"
+
+type PanicOptions struct {
+	// Fatal logs panics at FatalLevel instead of ErrorLevel
+	Fatal bool
+	// Repanic panics again with the recovered value once it was logged, instead of swallowing the panic
+	Repanic bool
+	// FlushTimeout bounds how long flushing outputs may take; defaults to DEFAULT_PANIC_FLUSH_TIMEOUT
+	FlushTimeout time.Duration
+}
+
+// RecoverPanic must be deferred directly, as in defer logger.RecoverPanic(opts). if the function panics,
+// it logs the panic value and the goroutine's stack (with the logger's tags), and flushes every output that buffers rows,
+// so that the row is not lost if the process is about to exit
+func (b *basicLogger) RecoverPanic(opts PanicOptions) {
+	r := recover()
+	if r == nil {
+		return
+	}
+
+	level := ErrorLevel
+	if opts.Fatal {
+		level = FatalLevel
+	}
+
+	params := []*Field{String("panic", fmt.Sprint(r)), String("stack", string(debug.Stack()))}
+	if err, ok := r.(error); ok {
+		params = append(params, Error(err))
+	}
+
+	// called directly so that the function that panicked is reported as the caller
+	b.log(true, level.String(), PANIC_MESSAGE, params...)
+
+	if opts.FlushTimeout <= 0 {
+		opts.FlushTimeout = DEFAULT_PANIC_FLUSH_TIMEOUT
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), opts.FlushTimeout)
+	defer cancel()
+	flushOutputs(ctx, b.outputs...)
+
+	if opts.Repanic {
+		panic(r)
+	}
+}
+
+// GoSafe runs fn in a new goroutine, logging and swallowing its panics with logger.RecoverPanic
+func GoSafe(logger Logger, fn func()) {
+	go func() {
+		defer logger.RecoverPanic(PanicOptions{})
+		fn()
+	}()
+}
+
+// flushOutputs flushes outputs that buffer rows, and the outputs they wrap; errors are ignored, as there is nowhere left to report them
+func flushOutputs(ctx context.Context, outputs ...Output) {
+	for _, output := range outputs {
+		flushOutput(ctx, output)
+
+		if w, ok := output.(wrappingOutput); ok {
+			flushOutputs(ctx, w.unwrap())
+		}
+	}
+}
+
+// outputs whose Flush does not take a context are flushed in a goroutine of their own, which is left behind once ctx is done
+func flushOutput(ctx context.Context, output Output) {
+	var flush func()
+	switch o := output.(type) {
+	case interface{ Flush(context.Context) error }:
+		_ = o.Flush(ctx)
+		return
+	case interface{ FlushContext(context.Context) error }:
+		_ = o.FlushContext(ctx)
+		return
+	case interface{ Flush() error }:
+		flush = func() { _ = o.Flush() }
+	case interface{ Flush() }:
+		flush = o.Flush
+	default:
+		return
+	}
+
+	if ctx.Err() != nil {
+		return
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		flush()
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+}
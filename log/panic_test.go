@@ -0,0 +1,107 @@
+// Copyright 2019 the orbs-network-go authors
+// This file is part of the orbs-network-go library in the Orbs project.
+//
+// This source code is licensed under the MIT license found in the LICENSE file in the root directory of this source tree.
+// The above notice should be included in all copies or substantial portions of the software.
+
+package log
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func panicWith(logger Logger, opts PanicOptions, value interface{}) {
+	defer logger.RecoverPanic(opts)
+	panic(value)
+}
+
+func TestRecoverPanic_LogsAndSwallows(t *testing.T) {
+	b := new(bytes.Buffer)
+	logger := GetLogger(Node("node1")).WithOutput(NewFormattingOutput(b, NewJsonFormatter()))
+
+	require.NotPanics(t, func() {
+		panicWith(logger, PanicOptions{}, "boom")
+	})
+
+	row := parseOutput(b.String())
+	require.Equal(t, "error", row["level"])
+	require.Equal(t, PANIC_MESSAGE, row["message"])
+	require.Equal(t, "boom", row["panic"])
+	require.Equal(t, "node1", row["node"])
+	require.Equal(t, "log.panicWith", row["function"])
+	require.Regexp(t, "goroutine \\d+ .*\n(.|\n)*log.panicWith", row["stack"])
+}
+
+func TestRecoverPanic_Repanics(t *testing.T) {
+	b := new(bytes.Buffer)
+	logger := GetLogger().WithOutput(NewFormattingOutput(b, NewJsonFormatter()))
+	err := errors.New("boom")
+
+	require.PanicsWithValue(t, err, func() {
+		panicWith(logger, PanicOptions{Fatal: true, Repanic: true}, err)
+	})
+
+	row := parseOutput(b.String())
+	require.Equal(t, "fatal", row["level"])
+	require.Equal(t, "boom", row["error.message"])
+}
+
+func TestRecoverPanic_FlushesBufferedOutputs(t *testing.T) {
+	b := &syncBuffer{}
+	bulk := NewBulkOutput(b, NewJsonFormatter(), 100)
+	async := NewAsyncOutput(NewRedactingOutput(bulk, NewRedactor()), AsyncOutputOptions{})
+	defer async.Close()
+
+	logger := GetLogger().WithOutput(async)
+	logger.Info("before")
+	panicWith(logger, PanicOptions{}, "boom")
+
+	rows := b.lines()
+	require.Len(t, rows, 2, "rows were left in the bulk")
+	require.Equal(t, "before", rows[0]["message"])
+	require.Equal(t, PANIC_MESSAGE, rows[1]["message"])
+}
+
+type stuckOutput struct {
+	unblock chan struct{}
+}
+
+func (o *stuckOutput) Append(onError func(err error), level string, message string, fields ...*Field) {
+}
+
+func (o *stuckOutput) SetFilters(filter ...Filter) {
+}
+
+func (o *stuckOutput) Flush() {
+	<-o.unblock
+}
+
+func TestRecoverPanic_GivesUpOnOutputsThatDoNotFlushInTime(t *testing.T) {
+	stuck := &stuckOutput{unblock: make(chan struct{})}
+	defer close(stuck.unblock)
+
+	start := time.Now()
+	panicWith(GetLogger().WithOutput(stuck), PanicOptions{FlushTimeout: 20 * time.Millisecond}, "boom")
+
+	require.True(t, time.Since(start) < time.Second, "flushing should give up once the timeout passes")
+}
+
+func TestGoSafe(t *testing.T) {
+	b := &syncBuffer{}
+	logger := GetLogger().WithOutput(NewFormattingOutput(b, NewJsonFormatter()))
+
+	GoSafe(logger, func() {
+		panic("boom")
+	})
+
+	for start := time.Now(); len(b.lines()) == 0 && time.Since(start) < time.Second; {
+		time.Sleep(10 * time.Millisecond)
+	}
+	require.Len(t, b.lines(), 1)
+	require.Equal(t, "boom", b.lines()[0]["panic"])
+}
@@ -0,0 +1,177 @@
+// Copyright 2019 the orbs-network-go authors
+// This file is part of the orbs-network-go library in the Orbs project.
+//
+// This source code is licensed under the MIT license found in the LICENSE file in the root directory of this source tree.
+// The above notice should be included in all copies or substantial portions of the software.
+
+package log
+
+import (
+	"container/list"
+	"strings"
+	"sync"
+	"time"
+)
+
+const DEFAULT_RATE_LIMIT_MAX_KEYS = 10000
+
+// the counter that rate limit filters report dropped rows in, labeled by the key fields
+const RATE_LIMITED_ROWS_METRIC = "log_rate_limited_rows"
+
+type tokenBucket struct {
+	key     string
+	labels  []*Field
+	tokens  float64
+	last    time.Time
+	dropped uint64 // since the last report
+}
+
+type droppedRows struct {
+	labels []*Field
+	count  uint64
+}
+
+type rateLimitFilter struct {
+	keyFields []string
+	rate      float64
+	burst     float64
+	maxKeys   int
+	reporter  Logger
+	now       func() time.Time
+
+	lock    sync.Mutex
+	buckets map[string]*list.Element
+	lru     *list.List // of *tokenBucket, most recently used first
+}
+
+// RateLimit allows rate rows per second with bursts of up to burst rows for each combination of the values of keyFields
+// (which may be inside aggregates; rows missing a field count as having an empty value), for example RateLimit([]string{"peer"}, 10, 100).
+// buckets of the least recently seen combinations are evicted once there are more than DEFAULT_RATE_LIMIT_MAX_KEYS of them, see WithMaxKeys.
+// rows with levels that are not severities (such as metrics) are always allowed
+func RateLimit(keyFields []string, rate float64, burst int) *rateLimitFilter {
+	if rate <= 0 || burst < 1 {
+		panic("rate limit must have a positive rate and a burst of at least 1")
+	}
+
+	return &rateLimitFilter{
+		keyFields: keyFields,
+		rate:      rate,
+		burst:     float64(burst),
+		maxKeys:   DEFAULT_RATE_LIMIT_MAX_KEYS,
+		now:       time.Now,
+		buckets:   make(map[string]*list.Element),
+		lru:       list.New(),
+	}
+}
+
+func (f *rateLimitFilter) WithMaxKeys(maxKeys int) *rateLimitFilter {
+	f.maxKeys = maxKeys
+	return f
+}
+
+// WithDropReports reports how many rows were dropped for each key as a RATE_LIMITED_ROWS_METRIC counter with logger.Metric,
+// when the key is allowed a row again, when its bucket is evicted, and on Flush
+func (f *rateLimitFilter) WithDropReports(logger Logger) *rateLimitFilter {
+	f.reporter = logger
+	return f
+}
+
+func (f *rateLimitFilter) Allows(level string, message string, fields []*Field) bool {
+	if _, ok := levelOf(level); !ok {
+		return true
+	}
+
+	key, labels := f.keyOf(fields)
+
+	// reports are made once the lock is released, as the reporting logger may use this filter too
+	allowed, reports := f.take(key, labels)
+	f.report(reports...)
+
+	return allowed
+}
+
+func (f *rateLimitFilter) take(key string, labels []*Field) (allowed bool, reports []droppedRows) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	now := f.now()
+
+	var bucket *tokenBucket
+	if element, ok := f.buckets[key]; ok {
+		f.lru.MoveToFront(element)
+		bucket = element.Value.(*tokenBucket)
+		bucket.tokens += now.Sub(bucket.last).Seconds() * f.rate
+		if bucket.tokens > f.burst {
+			bucket.tokens = f.burst
+		}
+	} else {
+		bucket = &tokenBucket{key: key, labels: labels, tokens: f.burst}
+		f.buckets[key] = f.lru.PushFront(bucket)
+		reports = f.evict()
+	}
+	bucket.last = now
+
+	if bucket.tokens < 1 {
+		bucket.dropped++
+		return false, reports
+	}
+
+	bucket.tokens--
+	if bucket.dropped > 0 {
+		reports = append(reports, droppedRows{labels: bucket.labels, count: bucket.dropped})
+		bucket.dropped = 0
+	}
+	return true, reports
+}
+
+// assumes lock
+func (f *rateLimitFilter) evict() (reports []droppedRows) {
+	for f.maxKeys > 0 && f.lru.Len() > f.maxKeys {
+		bucket := f.lru.Remove(f.lru.Back()).(*tokenBucket)
+		delete(f.buckets, bucket.key)
+		if bucket.dropped > 0 {
+			reports = append(reports, droppedRows{labels: bucket.labels, count: bucket.dropped})
+		}
+	}
+	return
+}
+
+// Flush reports the rows dropped since the last report of every key
+func (f *rateLimitFilter) Flush() {
+	var reports []droppedRows
+
+	f.lock.Lock()
+	for element := f.lru.Front(); element != nil; element = element.Next() {
+		bucket := element.Value.(*tokenBucket)
+		if bucket.dropped > 0 {
+			reports = append(reports, droppedRows{labels: bucket.labels, count: bucket.dropped})
+			bucket.dropped = 0
+		}
+	}
+	f.lock.Unlock()
+
+	f.report(reports...)
+}
+
+func (f *rateLimitFilter) report(reports ...droppedRows) {
+	if f.reporter == nil {
+		return
+	}
+
+	for _, r := range reports {
+		f.reporter.Metric(append(Counter(RATE_LIMITED_ROWS_METRIC, float64(r.count)), r.labels...)...)
+	}
+}
+
+func (f *rateLimitFilter) keyOf(fields []*Field) (key string, labels []*Field) {
+	values := make([]string, len(f.keyFields))
+	labels = make([]*Field, len(f.keyFields))
+	for i, keyField := range f.keyFields {
+		if field := findField(fields, func(field *Field) bool { return field.Key == keyField }); field != nil {
+			values[i] = logfmtValue(field)
+		}
+		labels[i] = String(keyField, values[i])
+	}
+
+	return strings.Join(values, "\x00"), labels
+}
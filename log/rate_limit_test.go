@@ -0,0 +1,106 @@
+// Copyright 2019 the orbs-network-go authors
+// This file is part of the orbs-network-go library in the Orbs project.
+//
+// This source code is licensed under the MIT license found in the LICENSE file in the root directory of this source tree.
+// The above notice should be included in all copies or substantial portions of the software.
+
+package log
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type fakeClock struct {
+	lock sync.Mutex
+	now  time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func rowsFrom(f Filter, times int, fields ...*Field) (allowed int) {
+	for i := 0; i < times; i++ {
+		if f.Allows("info", "foo", fields) {
+			allowed++
+		}
+	}
+	return
+}
+
+func TestRateLimit_TokenBucketPerKey(t *testing.T) {
+	clock := &fakeClock{now: time.Now()}
+	f := RateLimit([]string{"peer"}, 2, 5)
+	f.now = clock.Now
+
+	require.Equal(t, 5, rowsFrom(f, 10, String("peer", "a")), "should allow a burst")
+	require.Equal(t, 5, rowsFrom(f, 10, String("peer", "b"), Int("other", 1)), "peers should have buckets of their own")
+	require.Equal(t, 5, rowsFrom(f, 10, Aggregate("conn", String("peer", "c"))), "key fields should be found inside aggregates")
+
+	clock.Advance(time.Second)
+	require.Equal(t, 2, rowsFrom(f, 10, String("peer", "a")), "should refill at rate")
+
+	clock.Advance(time.Hour)
+	require.Equal(t, 5, rowsFrom(f, 10, String("peer", "a")), "should not refill beyond burst")
+
+	require.True(t, f.Allows("metric", "foo", []*Field{String("peer", "a")}), "metrics should not be limited")
+}
+
+func TestRateLimit_EvictsLeastRecentlyUsedBuckets(t *testing.T) {
+	f := RateLimit([]string{"peer", "error"}, 1, 1).WithMaxKeys(2)
+	f.now = (&fakeClock{now: time.Now()}).Now
+
+	rowsFrom(f, 1, String("peer", "a"))
+	rowsFrom(f, 1, String("peer", "b"))
+	rowsFrom(f, 1, String("peer", "a"))
+	rowsFrom(f, 1, String("peer", "c"))
+
+	require.Len(t, f.buckets, 2)
+	require.Contains(t, f.buckets, "a\x00")
+	require.NotContains(t, f.buckets, "b\x00", "b was the least recently used")
+	require.Equal(t, 1, rowsFrom(f, 1, String("peer", "b")), "evicted buckets should start full")
+}
+
+func TestRateLimit_ReportsDroppedRowsAsMetrics(t *testing.T) {
+	clock := &fakeClock{now: time.Now()}
+	b := new(bytes.Buffer)
+	logger := GetLogger().WithOutput(NewFormattingOutput(b, NewJsonFormatter()))
+	f := RateLimit([]string{"peer"}, 1, 1).WithDropReports(logger)
+	f.now = clock.Now
+	logger.WithFilters(f)
+
+	for i := 0; i < 4; i++ {
+		logger.Info("foo", String("peer", "a"))
+	}
+	require.Equal(t, 1, strings.Count(b.String(), "\n"), "no report until a row is allowed again")
+
+	clock.Advance(time.Second)
+	logger.Info("foo", String("peer", "a"))
+
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+	require.Len(t, lines, 3)
+
+	report := parseOutput(lines[1])
+	require.Equal(t, "metric", report["level"])
+	require.Equal(t, RATE_LIMITED_ROWS_METRIC, report[METRIC_NAME_KEY])
+	require.EqualValues(t, 3, report[METRIC_VALUE_KEY])
+	require.Equal(t, "a", report["peer"])
+
+	logger.Info("foo", String("peer", "a"))
+	f.Flush()
+	require.EqualValues(t, 1, parseOutput(strings.Split(strings.TrimSpace(b.String()), "\n")[3])[METRIC_VALUE_KEY])
+}
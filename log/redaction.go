@@ -0,0 +1,281 @@
+// Copyright 2019 the orbs-network-go authors
+// This file is part of the orbs-network-go library in the Orbs project.
+//
+// This source code is licensed under the MIT license found in the LICENSE file in the root directory of this source tree.
+// The above notice should be included in all copies or substantial portions of the software.
+
+package log
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"time"
+)
+
+const REDACTED = "[REDACTED]"
+
+// patterns for WithValuePattern that catch common secrets in free text
+const (
+	REDACT_JWT_PATTERN         = `eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`
+	REDACT_PRIVATE_KEY_PATTERN = `-----BEGIN [A-Z ]*PRIVATE KEY-----(?s:.*?)-----END [A-Z ]*PRIVATE KEY-----`
+	REDACT_EMAIL_PATTERN       = `[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`
+)
+
+// Redactor replaces sensitive values before rows reach outputs, see Logger.WithRedactor and NewRedactingOutput.
+// it must not modify the fields it is given, as they may be shared with other rows, and returns copies instead
+type Redactor interface {
+	Redact(fields []*Field) []*Field
+}
+
+// Redaction decides what is left of a sensitive value
+type Redaction interface {
+	Redact(value string) string
+}
+
+type fullMask struct{}
+
+type partialReveal struct {
+	prefix int
+	suffix int
+}
+
+type keyedHMAC struct {
+	key []byte
+}
+
+// FullMask replaces the whole value with REDACTED
+func FullMask() Redaction {
+	return fullMask{}
+}
+
+// PartialReveal keeps the first prefix and last suffix characters of the value and masks the rest,
+// so that "0x1234567890ab" becomes "0x12****90ab" with PartialReveal(4, 4). values too short to hide anything are masked fully
+func PartialReveal(prefix int, suffix int) Redaction {
+	if prefix < 0 || suffix < 0 {
+		panic(fmt.Sprintf("partial reveal needs a prefix and suffix of zero or more characters, got %d and %d", prefix, suffix))
+	}
+
+	return &partialReveal{prefix: prefix, suffix: suffix}
+}
+
+// KeyedHMAC replaces the value with a truncated HMAC-SHA256 of it, so that rows with the same value can be correlated
+// without revealing it; key should be kept secret, or short values can be found by brute force
+func KeyedHMAC(key []byte) Redaction {
+	return &keyedHMAC{key: key}
+}
+
+func (fullMask) Redact(value string) string {
+	return REDACTED
+}
+
+func (r *partialReveal) Redact(value string) string {
+	runes := []rune(value)
+	if len(runes) <= r.prefix+r.suffix {
+		return REDACTED
+	}
+
+	return string(runes[:r.prefix]) + "****" + string(runes[len(runes)-r.suffix:])
+}
+
+func (r *keyedHMAC) Redact(value string) string {
+	mac := hmac.New(sha256.New, r.key)
+	_, _ = mac.Write([]byte(value))
+	return "hmac:" + hex.EncodeToString(mac.Sum(nil)[:16])
+}
+
+type keyRedactionRule struct {
+	pattern   string
+	redaction Redaction
+}
+
+type valueRedactionRule struct {
+	pattern   *regexp.Regexp
+	redaction Redaction
+}
+
+type redactor struct {
+	keys    []keyRedactionRule
+	values  []valueRedactionRule
+	secrets Redaction
+}
+
+// NewRedactor returns a Redactor that fully masks Secret fields and leaves everything else alone until told otherwise with its With methods.
+// rules apply to fields inside aggregates and to every value of StringArray fields too
+func NewRedactor() *redactor {
+	return &redactor{secrets: FullMask()}
+}
+
+// WithKeys redacts the values of fields with any of keys, which are exact keys or globs such as "*token*".
+// keys of fields inside aggregates are also matched with the aggregate's name, as in "user.email"
+func (r *redactor) WithKeys(redaction Redaction, keys ...string) *redactor {
+	for _, key := range keys {
+		if _, err := path.Match(key, ""); err != nil {
+			panic("invalid redaction key pattern " + key + ": " + err.Error())
+		}
+		r.keys = append(r.keys, keyRedactionRule{pattern: key, redaction: redaction})
+	}
+	return r
+}
+
+// WithValuePattern redacts the parts of string, byte (as hex) and error values that match pattern, see the REDACT_*_PATTERN constants
+func (r *redactor) WithValuePattern(redaction Redaction, pattern string) *redactor {
+	r.values = append(r.values, valueRedactionRule{pattern: regexp.MustCompile(pattern), redaction: redaction})
+	return r
+}
+
+// WithSecretRedaction replaces how Secret fields are redacted, for example with KeyedHMAC to correlate them
+func (r *redactor) WithSecretRedaction(redaction Redaction) *redactor {
+	r.secrets = redaction
+	return r
+}
+
+func (r *redactor) Redact(fields []*Field) []*Field {
+	redacted, _ := r.redactFields("", fields)
+	return redacted
+}
+
+// fields are copied only if one of them is redacted
+func (r *redactor) redactFields(prefix string, fields []*Field) (redacted []*Field, changed bool) {
+	for i, f := range fields {
+		if replacement := r.redactField(prefix, f); replacement != f {
+			if redacted == nil {
+				redacted = make([]*Field, len(fields))
+				copy(redacted, fields)
+			}
+			redacted[i] = replacement
+		}
+	}
+
+	if redacted == nil {
+		return fields, false
+	}
+	return redacted, true
+}
+
+func (r *redactor) redactField(prefix string, f *Field) *Field {
+	if f == nil {
+		return nil
+	}
+
+	if f.IsNested() {
+		if redacted, changed := r.redactFields(prefix+f.Key+".", f.Nested.NestedFields()); changed {
+			return Aggregate(f.Key, redacted...)
+		}
+		return f
+	}
+
+	redaction := r.keyRedaction(prefix, f.Key)
+	if f.Type == SecretType {
+		if redaction == nil {
+			redaction = r.secrets
+		}
+		return String(f.Key, redaction.Redact(f.StringVal))
+	}
+
+	if redaction != nil {
+		return redactFieldWith(f, redaction.Redact)
+	}
+
+	if len(r.values) > 0 {
+		switch f.Type {
+		case StringType, StringArrayType, BytesType, ErrorType:
+			return redactFieldWith(f, r.redactMatches)
+		}
+	}
+
+	return f
+}
+
+func (r *redactor) keyRedaction(prefix string, key string) Redaction {
+	for _, rule := range r.keys {
+		if matched, _ := path.Match(rule.pattern, key); matched {
+			return rule.redaction
+		}
+		if prefix != "" {
+			if matched, _ := path.Match(rule.pattern, prefix+key); matched {
+				return rule.redaction
+			}
+		}
+	}
+
+	return nil
+}
+
+func (r *redactor) redactMatches(value string) string {
+	for _, rule := range r.values {
+		value = rule.pattern.ReplaceAllStringFunc(value, rule.redaction.Redact)
+	}
+	return value
+}
+
+// returns f itself if redact leaves its value as it was
+func redactFieldWith(f *Field, redact func(value string) string) *Field {
+	switch f.Type {
+	case StringType, NodeType, ServiceType, FunctionType, SourceType, TraceIDType, SpanIDType, TraceFlagsType:
+		if redacted := redact(f.StringVal); redacted != f.StringVal {
+			copied := *f
+			copied.StringVal = redacted
+			return &copied
+		}
+	case StringArrayType:
+		var values []string
+		for i, value := range f.StringArray {
+			if redacted := redact(value); redacted != value {
+				if values == nil {
+					values = append([]string(nil), f.StringArray...)
+				}
+				values[i] = redacted
+			}
+		}
+		if values != nil {
+			return &Field{Key: f.Key, StringArray: values, Type: StringArrayType}
+		}
+	default:
+		value := logfmtValue(f)
+		if redacted := redact(value); redacted != value {
+			if f.Type == ErrorType {
+				return &Field{Key: f.Key, Error: errors.New(redacted), Type: ErrorType}
+			}
+			return String(f.Key, redacted)
+		}
+	}
+
+	return f
+}
+
+// Secret is a String field whose value is never logged as is: formatters print REDACTED in its place,
+// and a Redactor replaces it according to WithSecretRedaction
+func Secret(key string, value string) *Field {
+	return &Field{Key: key, StringVal: value, Type: SecretType}
+}
+
+type redactingOutput struct {
+	inner    Output
+	redactor Redactor
+}
+
+// NewRedactingOutput redacts fields before passing rows on to inner, for redacting only what goes to some outputs
+func NewRedactingOutput(inner Output, redactor Redactor) Output {
+	return &redactingOutput{inner: inner, redactor: redactor}
+}
+
+func (out *redactingOutput) Append(onError func(err error), level string, message string, fields ...*Field) {
+	out.inner.Append(onError, level, message, out.redactor.Redact(fields)...)
+}
+
+func (out *redactingOutput) appendAt(onError func(err error), timestamp time.Time, level string, message string, fields ...*Field) {
+	appendAt(out.inner, onError, timestamp, level, message, out.redactor.Redact(fields)...)
+}
+
+func (out *redactingOutput) SetFilters(filters ...Filter) {
+	out.inner.SetFilters(filters...)
+}
+
+func (out *redactingOutput) unwrap() Output {
+	return out.inner
+}
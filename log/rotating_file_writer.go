@@ -0,0 +1,314 @@
+// Copyright 2019 the orbs-network-go authors
+// This file is part of the orbs-network-go library in the Orbs project.
+//
+// This source code is licensed under the MIT license found in the LICENSE file in the root directory of this source tree.
+// The above notice should be included in all copies or substantial portions of the software.
+
+package log
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const BACKUP_TIMESTAMP_FORMAT = "2006-01-02T15-04-05.000"
+
+type RotatingFileWriterOptions struct {
+	// rotate before the file grows beyond this many bytes, 0 means no size limit
+	MaxSize int64
+	// rotate when the file has been open this long, 0 means no time limit
+	RotateInterval time.Duration
+	// keep at most this many backups, 0 means no limit
+	MaxBackups int
+	// delete backups older than this, 0 means no limit
+	MaxAge time.Duration
+	// gzip backups in the background
+	Compress bool
+	// reopen the file on SIGHUP, for use with an external logrotate (not supported on windows)
+	ReopenOnSIGHUP bool
+}
+
+type rotatingFileWriter struct {
+	path string
+	opts RotatingFileWriterOptions
+
+	mu       sync.Mutex
+	f        *os.File
+	size     int64
+	openedAt time.Time
+
+	millLock    sync.Mutex
+	milling     sync.WaitGroup
+	stopSignals chan struct{}
+	closeOnce   sync.Once
+}
+
+// NewRotatingFileWriter appends to the file at path, and moves it aside to a timestamped backup
+// (e.g. node.log -> node-2006-01-02T15-04-05.000.log) when it reaches its size or age limit
+func NewRotatingFileWriter(path string, opts RotatingFileWriterOptions) (*rotatingFileWriter, error) {
+	w := &rotatingFileWriter{
+		path:        path,
+		opts:        opts,
+		stopSignals: make(chan struct{}),
+	}
+
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+
+	if opts.ReopenOnSIGHUP {
+		w.reopenOnSIGHUP()
+	}
+
+	return w, nil
+}
+
+// assumes lock
+func (w *rotatingFileWriter) open() error {
+	f, err := os.OpenFile(w.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	w.f = f
+	w.size = info.Size()
+	w.openedAt = time.Now()
+
+	return nil
+}
+
+func (w *rotatingFileWriter) Write(p []byte) (n int, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.f == nil {
+		return 0, os.ErrClosed
+	}
+
+	if w.shouldRotate(len(p)) {
+		if err := w.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err = w.f.Write(p)
+	w.size += int64(n)
+
+	return n, err
+}
+
+// assumes lock
+func (w *rotatingFileWriter) shouldRotate(incoming int) bool {
+	if w.size == 0 {
+		return false
+	}
+
+	if w.opts.MaxSize > 0 && w.size+int64(incoming) > w.opts.MaxSize {
+		return true
+	}
+
+	return w.opts.RotateInterval > 0 && time.Since(w.openedAt) >= w.opts.RotateInterval
+}
+
+// Rotate moves the current file to a backup and starts a new one
+func (w *rotatingFileWriter) Rotate() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.rotate()
+}
+
+// assumes lock
+func (w *rotatingFileWriter) rotate() error {
+	if err := w.f.Close(); err != nil {
+		return err
+	}
+	w.f = nil
+
+	if err := os.Rename(w.path, w.backupName(time.Now())); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	if err := w.open(); err != nil {
+		return err
+	}
+
+	w.milling.Add(1)
+	go w.mill()
+
+	return nil
+}
+
+// Reopen closes and reopens the file at path, for when something else moved it (such as logrotate)
+func (w *rotatingFileWriter) Reopen() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.f == nil {
+		return os.ErrClosed
+	}
+
+	if err := w.f.Close(); err != nil {
+		return err
+	}
+	w.f = nil
+
+	return w.open()
+}
+
+func (w *rotatingFileWriter) Close() error {
+	w.closeOnce.Do(func() {
+		close(w.stopSignals)
+	})
+
+	w.mu.Lock()
+	var err error
+	if w.f != nil {
+		err = w.f.Close()
+		w.f = nil
+	}
+	w.mu.Unlock()
+
+	w.milling.Wait()
+
+	return err
+}
+
+func (w *rotatingFileWriter) splitPath() (dir string, prefix string, ext string) {
+	dir = filepath.Dir(w.path)
+	base := filepath.Base(w.path)
+	ext = filepath.Ext(base)
+	prefix = strings.TrimSuffix(base, ext) + "-"
+
+	return
+}
+
+func (w *rotatingFileWriter) backupName(t time.Time) string {
+	dir, prefix, ext := w.splitPath()
+	name := filepath.Join(dir, prefix+t.UTC().Format(BACKUP_TIMESTAMP_FORMAT)+ext)
+
+	// two rotations within the same millisecond
+	for i := 1; fileExists(name) || fileExists(name+".gz"); i++ {
+		name = filepath.Join(dir, fmt.Sprintf("%s%s.%d%s", prefix, t.UTC().Format(BACKUP_TIMESTAMP_FORMAT), i, ext))
+	}
+
+	return name
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+type backup struct {
+	path      string
+	timestamp time.Time
+}
+
+func (w *rotatingFileWriter) backups() ([]*backup, error) {
+	dir, prefix, ext := w.splitPath()
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var backups []*backup
+	for _, f := range files {
+		name := strings.TrimSuffix(f.Name(), ".gz")
+		if f.IsDir() || !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, ext) {
+			continue
+		}
+
+		stamp := strings.TrimSuffix(strings.TrimPrefix(name, prefix), ext)
+		if len(stamp) < len(BACKUP_TIMESTAMP_FORMAT) {
+			continue
+		}
+
+		t, err := time.Parse(BACKUP_TIMESTAMP_FORMAT, stamp[:len(BACKUP_TIMESTAMP_FORMAT)])
+		if err != nil {
+			continue
+		}
+
+		backups = append(backups, &backup{filepath.Join(dir, f.Name()), t})
+	}
+
+	sort.Slice(backups, func(i, j int) bool {
+		if backups[i].timestamp.Equal(backups[j].timestamp) {
+			return backups[i].path > backups[j].path
+		}
+		return backups[i].timestamp.After(backups[j].timestamp)
+	})
+
+	return backups, nil
+}
+
+// compresses new backups and deletes old ones, in the background after each rotation
+func (w *rotatingFileWriter) mill() {
+	defer w.milling.Done()
+
+	w.millLock.Lock()
+	defer w.millLock.Unlock()
+
+	backups, err := w.backups()
+	if err != nil {
+		return // TODO log the failure to list backups?
+	}
+
+	cutoff := time.Now().Add(-w.opts.MaxAge)
+	for i, b := range backups {
+		if (w.opts.MaxBackups > 0 && i >= w.opts.MaxBackups) || (w.opts.MaxAge > 0 && b.timestamp.Before(cutoff)) {
+			_ = os.Remove(b.path)
+		} else if w.opts.Compress && !strings.HasSuffix(b.path, ".gz") {
+			_ = compressFile(b.path)
+		}
+	}
+}
+
+func compressFile(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(path+".gz.tmp", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+
+	gz := gzip.NewWriter(dst)
+	if _, err := io.Copy(gz, src); err != nil {
+		_ = dst.Close()
+		return err
+	}
+
+	if err := gz.Close(); err != nil {
+		_ = dst.Close()
+		return err
+	}
+
+	if err := dst.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(path+".gz.tmp", path+".gz"); err != nil {
+		return err
+	}
+
+	return os.Remove(path)
+}
@@ -0,0 +1,150 @@
+// Copyright 2019 the orbs-network-go authors
+// This file is part of the orbs-network-go library in the Orbs project.
+//
+// This source code is licensed under the MIT license found in the LICENSE file in the root directory of this source tree.
+// The above notice should be included in all copies or substantial portions of the software.
+
+package log
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newRotatingFileWriterHarness(t *testing.T, opts RotatingFileWriterOptions) (*rotatingFileWriter, string, func()) {
+	dir, err := ioutil.TempDir("", "rotatingFileWriter")
+	require.NoError(t, err)
+
+	w, err := NewRotatingFileWriter(filepath.Join(dir, "node.log"), opts)
+	require.NoError(t, err)
+
+	return w, dir, func() {
+		_ = w.Close()
+		_ = os.RemoveAll(dir)
+	}
+}
+
+func listBackups(t *testing.T, dir string) []string {
+	files, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+
+	var names []string
+	for _, f := range files {
+		if f.Name() != "node.log" {
+			names = append(names, f.Name())
+		}
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+func TestRotatingFileWriter_RotatesBySize(t *testing.T) {
+	w, dir, cleanup := newRotatingFileWriterHarness(t, RotatingFileWriterOptions{MaxSize: 6})
+	defer cleanup()
+
+	_, _ = w.Write([]byte(string1))
+	_, _ = w.Write([]byte(string2))
+	_, _ = w.Write([]byte(string3))
+	require.NoError(t, w.Close())
+
+	testFileContents(t, filepath.Join(dir, "node.log"), string3)
+	backups := listBackups(t, dir)
+	require.Len(t, backups, 1)
+	require.Regexp(t, `^node-\d{4}-\d{2}-\d{2}T\d{2}-\d{2}-\d{2}\.\d{3}\.log$`, backups[0])
+	testFileContents(t, filepath.Join(dir, backups[0]), string1+string2)
+}
+
+func TestRotatingFileWriter_RotatesByTime(t *testing.T) {
+	w, dir, cleanup := newRotatingFileWriterHarness(t, RotatingFileWriterOptions{RotateInterval: 20 * time.Millisecond})
+	defer cleanup()
+
+	_, _ = w.Write([]byte(string1))
+	_, _ = w.Write([]byte(string2))
+	time.Sleep(30 * time.Millisecond)
+	_, _ = w.Write([]byte(string3))
+	require.NoError(t, w.Close())
+
+	testFileContents(t, filepath.Join(dir, "node.log"), string3)
+	require.Len(t, listBackups(t, dir), 1)
+}
+
+func TestRotatingFileWriter_KeepsMaxBackups(t *testing.T) {
+	w, dir, cleanup := newRotatingFileWriterHarness(t, RotatingFileWriterOptions{MaxBackups: 2})
+	defer cleanup()
+
+	for i := 0; i < 4; i++ {
+		_, _ = w.Write([]byte(string1))
+		require.NoError(t, w.Rotate())
+	}
+	require.NoError(t, w.Close())
+
+	require.Len(t, listBackups(t, dir), 2)
+}
+
+func TestRotatingFileWriter_DeletesBackupsOlderThanMaxAge(t *testing.T) {
+	w, dir, cleanup := newRotatingFileWriterHarness(t, RotatingFileWriterOptions{MaxAge: time.Hour})
+	defer cleanup()
+
+	old := filepath.Join(dir, "node-"+time.Now().Add(-2*time.Hour).UTC().Format(BACKUP_TIMESTAMP_FORMAT)+".log")
+	require.NoError(t, ioutil.WriteFile(old, []byte(string1), 0644))
+
+	_, _ = w.Write([]byte(string2))
+	require.NoError(t, w.Rotate())
+	require.NoError(t, w.Close())
+
+	backups := listBackups(t, dir)
+	require.Len(t, backups, 1)
+	require.NotEqual(t, filepath.Base(old), backups[0])
+}
+
+func TestRotatingFileWriter_CompressesBackups(t *testing.T) {
+	w, dir, cleanup := newRotatingFileWriterHarness(t, RotatingFileWriterOptions{Compress: true})
+	defer cleanup()
+
+	_, _ = w.Write([]byte(string1))
+	require.NoError(t, w.Rotate())
+	require.NoError(t, w.Close())
+
+	backups := listBackups(t, dir)
+	require.Len(t, backups, 1)
+	require.Regexp(t, `\.log\.gz$`, backups[0])
+
+	f, err := os.Open(filepath.Join(dir, backups[0]))
+	require.NoError(t, err)
+	defer closeSilently(f)
+	gz, err := gzip.NewReader(f)
+	require.NoError(t, err)
+	contents, err := ioutil.ReadAll(gz)
+	require.NoError(t, err)
+	require.Equal(t, string1, string(contents))
+}
+
+func TestRotatingFileWriter_ReopenAfterExternalRotation(t *testing.T) {
+	w, dir, cleanup := newRotatingFileWriterHarness(t, RotatingFileWriterOptions{})
+	defer cleanup()
+
+	_, _ = w.Write([]byte(string1))
+	require.NoError(t, os.Rename(filepath.Join(dir, "node.log"), filepath.Join(dir, "node.log.1")))
+	require.NoError(t, w.Reopen())
+	_, _ = w.Write([]byte(string2))
+
+	testFileContents(t, filepath.Join(dir, "node.log.1"), string1)
+	testFileContents(t, filepath.Join(dir, "node.log"), string2)
+}
+
+func TestRotatingFileWriter_FailsAfterClose(t *testing.T) {
+	w, _, cleanup := newRotatingFileWriterHarness(t, RotatingFileWriterOptions{})
+	defer cleanup()
+
+	require.NoError(t, w.Close())
+	_, err := w.Write([]byte(string1))
+	require.Error(t, err)
+}
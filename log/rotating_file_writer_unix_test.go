@@ -0,0 +1,38 @@
+// Copyright 2019 the orbs-network-go authors
+// This file is part of the orbs-network-go library in the Orbs project.
+//
+// This source code is licensed under the MIT license found in the LICENSE file in the root directory of this source tree.
+// The above notice should be included in all copies or substantial portions of the software.
+
+//go:build !windows
+// +build !windows
+
+package log
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRotatingFileWriter_ReopensOnSIGHUP(t *testing.T) {
+	w, dir, cleanup := newRotatingFileWriterHarness(t, RotatingFileWriterOptions{ReopenOnSIGHUP: true})
+	defer cleanup()
+
+	_, _ = w.Write([]byte(string1))
+	require.NoError(t, os.Rename(filepath.Join(dir, "node.log"), filepath.Join(dir, "node.log.1")))
+	require.NoError(t, syscall.Kill(os.Getpid(), syscall.SIGHUP))
+
+	deadline := time.Now().Add(time.Second)
+	for !fileExists(filepath.Join(dir, "node.log")) {
+		require.True(t, time.Now().Before(deadline), "file was not reopened")
+		time.Sleep(time.Millisecond)
+	}
+
+	_, _ = w.Write([]byte(string2))
+	testFileContents(t, filepath.Join(dir, "node.log"), string2)
+}
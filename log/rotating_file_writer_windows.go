@@ -0,0 +1,11 @@
+// Copyright 2019 the orbs-network-go authors
+// This file is part of the orbs-network-go library in the Orbs project.
+//
+// This source code is licensed under the MIT license found in the LICENSE file in the root directory of this source tree.
+// The above notice should be included in all copies or substantial portions of the software.
+
+package log
+
+// there is no SIGHUP on windows, call Reopen instead
+func (w *rotatingFileWriter) reopenOnSIGHUP() {
+}
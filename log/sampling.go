@@ -0,0 +1,148 @@
+// Copyright 2019 the orbs-network-go authors
+// This file is part of the orbs-network-go library in the Orbs project.
+//
+// This source code is licensed under the MIT license found in the LICENSE file in the root directory of this source tree.
+// The above notice should be included in all copies or substantial portions of the software.
+
+package log
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const DEFAULT_SAMPLING_TICK = time.Second
+const DEFAULT_SAMPLING_FIRST = 100
+
+// the message of the rows that report how many rows were sampled out, which are never sampled themselves
+const SAMPLING_SUMMARY_MESSAGE = "sampled out repeated log rows"
+
+type SamplingOptions struct {
+	// Tick is how often counts start over; defaults to DEFAULT_SAMPLING_TICK
+	Tick time.Duration
+	// First is how many rows of each level and message are allowed per tick; defaults to DEFAULT_SAMPLING_FIRST
+	First int
+	// Thereafter allows every Thereafter-th row once First have been allowed; 0 allows none of them
+	Thereafter int
+	// Summary, if not nil, gets a SAMPLING_SUMMARY_MESSAGE row at the end of every tick for each message that had rows sampled out
+	Summary Logger
+}
+
+type samplingKey struct {
+	level   string
+	message string
+}
+
+type samplingCounter struct {
+	count      uint64
+	suppressed uint64
+}
+
+type samplingFilter struct {
+	tick       time.Duration
+	first      uint64
+	thereafter uint64
+	summary    Logger
+
+	counters   sync.Map // samplingKey -> *samplingCounter
+	closed     chan struct{}
+	closeOnce  sync.Once
+	tickerDone chan struct{}
+}
+
+// Sampling allows the first rows of each level and message in every tick, and then only every Thereafter-th one,
+// so that messages logged in tight loops do not flood outputs. rows with levels that are not severities (such as metrics) are always allowed.
+// counting is lock free, so it can be used as a logger filter on hot paths; Close stops its ticker
+func Sampling(opts SamplingOptions) *samplingFilter {
+	if opts.Tick <= 0 {
+		opts.Tick = DEFAULT_SAMPLING_TICK
+	}
+
+	if opts.First <= 0 {
+		opts.First = DEFAULT_SAMPLING_FIRST
+	}
+
+	if opts.Thereafter < 0 {
+		panic("sampling Thereafter must not be negative")
+	}
+
+	f := &samplingFilter{
+		tick:       opts.Tick,
+		first:      uint64(opts.First),
+		thereafter: uint64(opts.Thereafter),
+		summary:    opts.Summary,
+		closed:     make(chan struct{}),
+		tickerDone: make(chan struct{}),
+	}
+
+	go f.resetPeriodically()
+
+	return f
+}
+
+func (f *samplingFilter) Allows(level string, message string, fields []*Field) bool {
+	if _, ok := levelOf(level); !ok || message == SAMPLING_SUMMARY_MESSAGE {
+		return true
+	}
+
+	key := samplingKey{level: level, message: message}
+	counter, ok := f.counters.Load(key)
+	if !ok {
+		counter, _ = f.counters.LoadOrStore(key, &samplingCounter{})
+	}
+	c := counter.(*samplingCounter)
+
+	n := atomic.AddUint64(&c.count, 1)
+	if n <= f.first || (f.thereafter > 0 && (n-f.first)%f.thereafter == 0) {
+		return true
+	}
+
+	atomic.AddUint64(&c.suppressed, 1)
+	return false
+}
+
+func (f *samplingFilter) resetPeriodically() {
+	defer close(f.tickerDone)
+
+	ticker := time.NewTicker(f.tick)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			f.reset()
+		case <-f.closed:
+			return
+		}
+	}
+}
+
+// counters of messages that were not logged during the tick are dropped, so that the map does not grow with every message ever logged
+func (f *samplingFilter) reset() {
+	f.counters.Range(func(key, value interface{}) bool {
+		c := value.(*samplingCounter)
+		if atomic.SwapUint64(&c.count, 0) == 0 {
+			f.counters.Delete(key)
+			return true
+		}
+
+		if suppressed := atomic.SwapUint64(&c.suppressed, 0); suppressed > 0 && f.summary != nil {
+			k := key.(samplingKey)
+			f.summary.Log(k.level, SAMPLING_SUMMARY_MESSAGE,
+				String("sampled-message", k.message),
+				Uint64("suppressed", suppressed),
+				String("sampling-tick", f.tick.String()))
+		}
+		return true
+	})
+}
+
+// Close stops the ticker and reports the rows sampled out in the current tick
+func (f *samplingFilter) Close() {
+	f.closeOnce.Do(func() {
+		close(f.closed)
+		<-f.tickerDone
+		f.reset()
+	})
+}
@@ -0,0 +1,98 @@
+// Copyright 2019 the orbs-network-go authors
+// This file is part of the orbs-network-go library in the Orbs project.
+//
+// This source code is licensed under the MIT license found in the LICENSE file in the root directory of this source tree.
+// The above notice should be included in all copies or substantial portions of the software.
+
+package log
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func countAllowed(f Filter, level string, message string, times int) (allowed int) {
+	for i := 0; i < times; i++ {
+		if f.Allows(level, message, nil) {
+			allowed++
+		}
+	}
+	return
+}
+
+func TestSampling_FirstThenEveryMth(t *testing.T) {
+	f := Sampling(SamplingOptions{Tick: time.Hour, First: 2, Thereafter: 3})
+	defer f.Close()
+
+	require.Equal(t, 4, countAllowed(f, "info", "foo", 10), "should allow rows 1, 2, 5 and 8")
+	require.Equal(t, 2, countAllowed(f, "info", "bar", 2), "messages should be counted separately")
+	require.Equal(t, 2, countAllowed(f, "warn", "foo", 2), "levels should be counted separately")
+	require.Equal(t, 10, countAllowed(f, "metric", "foo", 10), "metrics should not be sampled")
+
+	f.reset()
+	require.Equal(t, 2, countAllowed(f, "info", "foo", 3), "counts should start over every tick")
+}
+
+func TestSampling_WithoutThereafterDropsTheRest(t *testing.T) {
+	f := Sampling(SamplingOptions{Tick: time.Hour, First: 3})
+	defer f.Close()
+
+	require.Equal(t, 3, countAllowed(f, "info", "foo", 100))
+}
+
+func TestSampling_SummarizesSuppressedRowsEveryTick(t *testing.T) {
+	b := new(bytes.Buffer)
+	f := Sampling(SamplingOptions{Tick: time.Hour, First: 1, Summary: GetLogger().WithOutput(NewFormattingOutput(b, NewJsonFormatter()))})
+	logger := GetLogger().WithOutput(NewFormattingOutput(b, NewJsonFormatter())).WithFilters(f)
+
+	for i := 0; i < 5; i++ {
+		logger.Error("consensus round failed")
+	}
+	logger.Info("once")
+	f.Close()
+
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+	require.Len(t, lines, 3)
+
+	summary := parseOutput(lines[2])
+	require.Equal(t, SAMPLING_SUMMARY_MESSAGE, summary["message"])
+	require.Equal(t, "error", summary["level"])
+	require.Equal(t, "consensus round failed", summary["sampled-message"])
+	require.EqualValues(t, 4, summary["suppressed"])
+}
+
+func TestSampling_ForgetsMessagesThatWereNotLoggedDuringATick(t *testing.T) {
+	f := Sampling(SamplingOptions{Tick: time.Hour, First: 1})
+	defer f.Close()
+
+	f.Allows("info", "foo", nil)
+	f.reset()
+	f.reset()
+
+	_, ok := f.counters.Load(samplingKey{level: "info", message: "foo"})
+	require.False(t, ok)
+}
+
+func TestSampling_ConcurrentRows(t *testing.T) {
+	f := Sampling(SamplingOptions{Tick: time.Hour, First: 10, Thereafter: 10})
+	defer f.Close()
+
+	var allowed int64
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			atomic.AddInt64(&allowed, int64(countAllowed(f, "info", "foo", 100)))
+		}()
+	}
+	wg.Wait()
+
+	require.EqualValues(t, 10+99, allowed)
+}
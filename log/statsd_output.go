@@ -0,0 +1,284 @@
+// Copyright 2019 the orbs-network-go authors
+// This file is part of the orbs-network-go library in the Orbs project.
+//
+// This source code is licensed under the MIT license found in the LICENSE file in the root directory of this source tree.
+// The above notice should be included in all copies or substantial portions of the software.
+
+package log
+
+import (
+	"bytes"
+	"math/rand"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// optional field of metric rows, the fraction of events that the row stands for
+const METRIC_SAMPLE_RATE_KEY = "metric-sample-rate"
+
+// fits an ethernet frame after IP and UDP headers
+const DEFAULT_STATSD_MTU = 1432
+const DEFAULT_STATSD_FLUSH_INTERVAL = 100 * time.Millisecond
+
+// SampleRate marks a metric row as standing for a fraction of the events, for example:
+//
+//	logger.Metric(append(log.Counter("requests", 1), log.SampleRate(0.1))...)
+//
+// the statsd output sends such rows with that probability, and tells the agent the rate so it can scale them back up
+func SampleRate(rate float64) *Field {
+	return Float64(METRIC_SAMPLE_RATE_KEY, rate)
+}
+
+type StatsdOutputOptions struct {
+	// host:port of the agent
+	Address string
+	// prepended to every metric name, separated by a dot
+	Prefix string
+	// packets are filled with lines up to this many bytes, DEFAULT_STATSD_MTU if not positive
+	MTU int
+	// pending lines are sent at least this often, DEFAULT_STATSD_FLUSH_INTERVAL if not positive
+	FlushInterval time.Duration
+	// send Node and Service fields, and fields with TagKeys, as DogStatsD tags, and histograms as DogStatsD histograms instead of timers
+	DogStatsD bool
+	// keys of string and integer fields that are sent as DogStatsD tags, see MetricsOutputOptions.LabelKeys
+	TagKeys []string
+}
+
+type statsdOutput struct {
+	conn      net.Conn
+	prefix    string
+	mtu       int
+	dogStatsD bool
+	tagKeys   []string
+	filters   and
+
+	lock        sync.Mutex
+	packet      *bytes.Buffer
+	lastOnError func(err error)
+	random      *rand.Rand
+
+	closed     chan struct{}
+	closeOnce  sync.Once
+	tickerDone chan struct{}
+}
+
+// NewStatsdOutput sends metric rows (see Counter, Gauge and Histogram) to a StatsD or DogStatsD agent over UDP; rows of other levels are ignored
+func NewStatsdOutput(opts StatsdOutputOptions) (*statsdOutput, error) {
+	conn, err := net.Dial("udp", opts.Address)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.MTU <= 0 {
+		opts.MTU = DEFAULT_STATSD_MTU
+	}
+
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = DEFAULT_STATSD_FLUSH_INTERVAL
+	}
+
+	out := &statsdOutput{
+		conn:       conn,
+		prefix:     opts.Prefix,
+		mtu:        opts.MTU,
+		dogStatsD:  opts.DogStatsD,
+		tagKeys:    opts.TagKeys,
+		packet:     new(bytes.Buffer),
+		random:     rand.New(rand.NewSource(time.Now().UnixNano())),
+		closed:     make(chan struct{}),
+		tickerDone: make(chan struct{}),
+	}
+
+	go out.flushPeriodically(opts.FlushInterval)
+
+	return out, nil
+}
+
+func (out *statsdOutput) SetFilters(filters ...Filter) {
+	out.filters = and{filters}
+}
+
+func (out *statsdOutput) Append(onError func(err error), level string, message string, fields ...*Field) {
+	if level != "metric" || !out.filters.Allows(level, message, fields) {
+		return
+	}
+
+	line, rate, err := out.formatLine(fields)
+	if err != nil {
+		onError(err)
+		return
+	}
+	if line == "" {
+		return // a metric row that does not follow the convention
+	}
+
+	out.lock.Lock()
+	defer out.lock.Unlock()
+
+	if rate < 1 && out.random.Float64() >= rate {
+		return
+	}
+
+	out.lastOnError = onError
+
+	if out.packet.Len() > 0 && out.packet.Len()+1+len(line) > out.mtu {
+		out.send()
+	}
+
+	if out.packet.Len() > 0 {
+		out.packet.WriteByte('\n')
+	}
+	out.packet.WriteString(line)
+}
+
+// returns an empty line for rows without a metric name
+func (out *statsdOutput) formatLine(fields []*Field) (line string, rate float64, err error) {
+	var name, kind, value *Field
+	var tags []*Field
+	rate = 1
+
+	visitFields(fields, func(f *Field) bool {
+		switch f.Key {
+		case METRIC_NAME_KEY:
+			name = f
+		case METRIC_KIND_KEY:
+			kind = f
+		case METRIC_VALUE_KEY:
+			value = f
+		case METRIC_SAMPLE_RATE_KEY:
+			rate = f.Float
+		default:
+			if isLabelField(f, out.tagKeys) {
+				tags = append(tags, f)
+			}
+		}
+		return true
+	})
+
+	if name == nil {
+		return "", 0, nil
+	}
+
+	v, ok := metricValue(value)
+	if !ok {
+		return "", 0, errors.Errorf("metric %s has no numeric %s field", name.StringVal, METRIC_VALUE_KEY)
+	}
+
+	if rate <= 0 || rate > 1 {
+		return "", 0, errors.Errorf("metric %s has sample rate %v, which is not in (0, 1]", name.StringVal, rate)
+	}
+
+	k := MetricKindGauge
+	if kind != nil {
+		k = MetricKind(kind.StringVal)
+	}
+
+	var statsdType string
+	switch k {
+	case MetricKindCounter:
+		statsdType = "c"
+	case MetricKindGauge:
+		statsdType = "g"
+	case MetricKindHistogram:
+		if out.dogStatsD {
+			statsdType = "h"
+		} else {
+			statsdType = "ms"
+		}
+	default:
+		return "", 0, errors.Errorf("metric %s has unknown kind %s", name.StringVal, k)
+	}
+
+	var sb strings.Builder
+	if out.prefix != "" {
+		sb.WriteString(sanitizeStatsd(out.prefix))
+		sb.WriteString(".")
+	}
+	sb.WriteString(sanitizeStatsd(name.StringVal))
+	sb.WriteString(":")
+	sb.WriteString(strconv.FormatFloat(v, 'f', -1, 64))
+	sb.WriteString("|")
+	sb.WriteString(statsdType)
+
+	if rate < 1 {
+		sb.WriteString("|@")
+		sb.WriteString(strconv.FormatFloat(rate, 'f', -1, 64))
+	}
+
+	if out.dogStatsD && len(tags) > 0 {
+		sb.WriteString("|#")
+		for i, tag := range tags {
+			if i > 0 {
+				sb.WriteString(",")
+			}
+			sb.WriteString(sanitizeStatsd(tag.Key))
+			sb.WriteString(":")
+			sb.WriteString(sanitizeStatsd(logfmtValue(tag)))
+		}
+	}
+
+	return sb.String(), rate, nil
+}
+
+var statsdEscaper = strings.NewReplacer(":", "_", "|", "_", "@", "_", "#", "_", ",", "_", "\n", "_")
+
+// characters that delimit parts of a statsd line can't appear in names, tags or values
+func sanitizeStatsd(value string) string {
+	return statsdEscaper.Replace(value)
+}
+
+// reporting under the lock is safe because the logger reports errors in error rows, which this output ignores.
+// assumes lock
+func (out *statsdOutput) send() {
+	if out.packet.Len() == 0 {
+		return
+	}
+
+	_, err := out.conn.Write(out.packet.Bytes())
+	out.packet.Reset()
+
+	if err != nil && out.lastOnError != nil {
+		out.lastOnError(errors.Errorf("failed to send metrics: %s", err))
+	}
+}
+
+// Flush sends the pending lines
+func (out *statsdOutput) Flush() {
+	out.lock.Lock()
+	defer out.lock.Unlock()
+
+	out.send()
+}
+
+func (out *statsdOutput) flushPeriodically(interval time.Duration) {
+	defer close(out.tickerDone)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			out.Flush()
+		case <-out.closed:
+			return
+		}
+	}
+}
+
+// Close stops the flush ticker, sends the pending lines and closes the connection
+func (out *statsdOutput) Close() error {
+	out.closeOnce.Do(func() {
+		close(out.closed)
+	})
+	<-out.tickerDone
+
+	out.Flush()
+
+	return out.conn.Close()
+}
@@ -0,0 +1,129 @@
+// Copyright 2019 the orbs-network-go authors
+// This file is part of the orbs-network-go library in the Orbs project.
+//
+// This source code is licensed under the MIT license found in the LICENSE file in the root directory of this source tree.
+// The above notice should be included in all copies or substantial portions of the software.
+
+package log
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func listenUDP(t *testing.T) *net.UDPConn {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+	return conn
+}
+
+func readPacket(t *testing.T, conn *net.UDPConn) string {
+	buf := make([]byte, 65536)
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+	n, err := conn.Read(buf)
+	require.NoError(t, err)
+	return string(buf[:n])
+}
+
+func newTestStatsdOutput(t *testing.T, conn *net.UDPConn, opts StatsdOutputOptions) *statsdOutput {
+	opts.Address = conn.LocalAddr().String()
+	if opts.FlushInterval == 0 {
+		opts.FlushInterval = time.Hour
+	}
+	output, err := NewStatsdOutput(opts)
+	require.NoError(t, err)
+	return output
+}
+
+func TestStatsdOutput_SendsMetricRows(t *testing.T) {
+	conn := listenUDP(t)
+	defer conn.Close()
+	output := newTestStatsdOutput(t, conn, StatsdOutputOptions{Prefix: "orbs"})
+	defer output.Close()
+
+	logger := GetLogger(Node("node1")).WithOutput(output)
+	logger.Metric(Counter("blocks", 2)...)
+	logger.Metric(Gauge("peers", 5.5)...)
+	logger.Metric(Histogram("latency", 12)...)
+	logger.Info("not a metric")
+	output.Flush()
+
+	require.Equal(t, "orbs.blocks:2|c\norbs.peers:5.5|g\norbs.latency:12|ms", readPacket(t, conn))
+}
+
+func TestStatsdOutput_DogStatsDTags(t *testing.T) {
+	conn := listenUDP(t)
+	defer conn.Close()
+	output := newTestStatsdOutput(t, conn, StatsdOutputOptions{DogStatsD: true, TagKeys: []string{"vcid", "path"}})
+	defer output.Close()
+
+	GetLogger(Node("node1"), Int("vcid", 42)).WithOutput(output).
+		Metric(append(Histogram("latency", 12), String("path", "a|b"))...)
+	output.Flush()
+
+	require.Equal(t, "latency:12|h|#node:node1,vcid:42,path:a_b", readPacket(t, conn))
+}
+
+func TestStatsdOutput_BatchesUpToMTU(t *testing.T) {
+	conn := listenUDP(t)
+	defer conn.Close()
+	output := newTestStatsdOutput(t, conn, StatsdOutputOptions{MTU: 20})
+	defer output.Close()
+
+	logger := GetLogger().WithOutput(output)
+	logger.Metric(Counter("aaaa", 1)...) // 8 bytes
+	logger.Metric(Counter("bbbb", 1)...) // 8 more bytes and a newline
+	logger.Metric(Counter("cccc", 1)...) // would exceed 20 bytes
+	output.Flush()
+
+	require.Equal(t, "aaaa:1|c\nbbbb:1|c", readPacket(t, conn))
+	require.Equal(t, "cccc:1|c", readPacket(t, conn))
+}
+
+func TestStatsdOutput_KeepsSampleRate(t *testing.T) {
+	conn := listenUDP(t)
+	defer conn.Close()
+	output := newTestStatsdOutput(t, conn, StatsdOutputOptions{MTU: 65000})
+	defer output.Close()
+
+	logger := GetLogger().WithOutput(output)
+	for i := 0; i < 1000; i++ {
+		logger.Metric(append(Counter("requests", 1), SampleRate(0.5))...)
+	}
+	output.Flush()
+
+	packet := readPacket(t, conn)
+	require.Regexp(t, `^requests:1\|c\|@0.5\n`, packet)
+
+	sent := len(packet) / len("requests:1|c|@0.5\n")
+	require.True(t, sent > 350 && sent < 650, "sent %d of 1000 rows sampled at 0.5", sent)
+}
+
+func TestStatsdOutput_FlushesPeriodically(t *testing.T) {
+	conn := listenUDP(t)
+	defer conn.Close()
+	output := newTestStatsdOutput(t, conn, StatsdOutputOptions{FlushInterval: 10 * time.Millisecond})
+	defer output.Close()
+
+	GetLogger().WithOutput(output).Metric(Gauge("peers", 5)...)
+
+	require.Equal(t, "peers:5|g", readPacket(t, conn))
+}
+
+func TestStatsdOutput_ReportsInvalidRows(t *testing.T) {
+	conn := listenUDP(t)
+	defer conn.Close()
+	output := newTestStatsdOutput(t, conn, StatsdOutputOptions{})
+	defer output.Close()
+
+	var errs []error
+	onError := func(err error) { errs = append(errs, err) }
+	output.Append(onError, "metric", "", String(METRIC_NAME_KEY, "foo"))
+	output.Append(onError, "metric", "", append(Counter("foo", 1), SampleRate(2))...)
+	output.Append(onError, "metric", "", Uint64("async-output-dropped-rows", 1))
+
+	require.Len(t, errs, 2)
+}
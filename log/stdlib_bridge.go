@@ -0,0 +1,247 @@
+// Copyright 2019 the orbs-network-go authors
+// This file is part of the orbs-network-go library in the Orbs project.
+//
+// This source code is licensed under the MIT license found in the LICENSE file in the root directory of this source tree.
+// The above notice should be included in all copies or substantial portions of the software.
+
+package log
+
+import (
+	"bytes"
+	stdlog "log"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// longer lines are split, so that a writer that never writes a newline cannot grow the buffer forever
+const MAX_BRIDGED_LINE_LENGTH = 64 * 1024
+
+// LineWriter is an io.Writer that logs every line written to it as a row, see NewLineWriter
+type LineWriter struct {
+	logger    Logger
+	level     string
+	keyValues bool
+
+	lock    sync.Mutex
+	partial []byte
+}
+
+// implemented by basicLogger, so that bridged rows report the code that wrote the line instead of the bridge
+type callSiteLogger interface {
+	logAt(logLoggingErrors bool, function string, source string, level string, message string, params ...*Field)
+}
+
+// NewLineWriter returns a writer that logs every line written to it as a row at level, for libraries that log to an io.Writer.
+// a line that does not end with a newline yet is held back until it does, or until Flush
+func NewLineWriter(logger Logger, level string) *LineWriter {
+	return &LineWriter{logger: logger, level: level}
+}
+
+// RedirectStdLog makes the standard library's log package write to logger at info level, without the timestamp it adds by default.
+// call log.SetOutput(os.Stderr) and log.SetFlags(log.LstdFlags) of the standard library to undo it
+func RedirectStdLog(logger Logger) *LineWriter {
+	w := NewLineWriter(logger, InfoLevel.String())
+	stdlog.SetFlags(0)
+	stdlog.SetPrefix("")
+	stdlog.SetOutput(w)
+	return w
+}
+
+// WithKeyValues turns key=value and key="quoted value" pairs in lines into String fields. the rest of the line is the message,
+// unless there is a msg pair; a level pair with a known level replaces the level of the row, and is left in the text otherwise
+func (w *LineWriter) WithKeyValues() *LineWriter {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	w.keyValues = true
+	return w
+}
+
+func (w *LineWriter) Write(p []byte) (n int, err error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	w.partial = append(w.partial, p...)
+	for {
+		i := bytes.IndexByte(w.partial, '\n')
+		if i < 0 {
+			break
+		}
+		w.emit(w.partial[:i])
+		w.partial = w.partial[i+1:]
+	}
+
+	for len(w.partial) >= MAX_BRIDGED_LINE_LENGTH {
+		cut := runeBoundaryBefore(w.partial, MAX_BRIDGED_LINE_LENGTH)
+		w.emit(w.partial[:cut])
+		w.partial = w.partial[cut:]
+	}
+
+	if len(w.partial) == 0 {
+		w.partial = nil // releases what was appended
+	}
+
+	return len(p), nil
+}
+
+// Flush logs the line held back because it did not end with a newline, if there is one
+func (w *LineWriter) Flush() {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	w.emit(w.partial)
+	w.partial = nil
+}
+
+// assumes lock
+func (w *LineWriter) emit(line []byte) {
+	text := strings.TrimRight(string(line), "\r")
+	if strings.TrimSpace(text) == "" {
+		return
+	}
+
+	level, message, fields := w.level, text, []*Field(nil)
+	if w.keyValues {
+		level, message, fields = parseKeyValues(w.level, text)
+	}
+
+	if logger, ok := w.logger.(callSiteLogger); ok {
+		function, source := bridgedCaller()
+		logger.logAt(true, function, source, level, message, fields...)
+	} else {
+		w.logger.Log(level, message, fields...)
+	}
+}
+
+// the prefix of the names of LineWriter's methods, found at runtime so that it follows the package wherever it is vendored
+// (WithKeyValues is used because the methods that write would refer back to this variable)
+var lineWriterFunctionPrefix = strings.TrimSuffix(runtime.FuncForPC(reflect.ValueOf((*LineWriter).WithKeyValues).Pointer()).Name(), "WithKeyValues")
+
+// packages that write to an io.Writer on behalf of the code that logged the line
+var bridgingPackagePrefixes = []string{"log.", "fmt.", "io.", "bufio."}
+
+// bridgedCaller returns the first frame outside of LineWriter and the packages that write to it, which is the code that wrote the line
+func bridgedCaller() (function string, source string) {
+	pcs := make([]uintptr, 32)
+	n := runtime.Callers(3, pcs) // skips runtime.Callers, bridgedCaller and emit
+	frames := runtime.CallersFrames(pcs[:n])
+
+	for {
+		frame, more := frames.Next()
+		if !isBridgeFrame(frame.Function) {
+			return formatCallSite(frame.Function, frame.File, frame.Line)
+		}
+		if !more {
+			return "n/a", "n/a"
+		}
+	}
+}
+
+func isBridgeFrame(function string) bool {
+	if strings.HasPrefix(function, lineWriterFunctionPrefix) {
+		return true
+	}
+
+	for _, prefix := range bridgingPackagePrefixes {
+		if strings.HasPrefix(function, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// runeBoundaryBefore returns max, or the start of the rune that max falls inside of, so that a split line stays valid UTF-8
+func runeBoundaryBefore(p []byte, max int) int {
+	if max >= len(p) {
+		return len(p)
+	}
+
+	for cut := max; cut > 0 && cut > max-utf8.UTFMax; cut-- {
+		if utf8.RuneStart(p[cut]) {
+			return cut
+		}
+	}
+
+	return max // not UTF-8 anyway
+}
+
+func parseKeyValues(defaultLevel string, line string) (level string, message string, fields []*Field) {
+	level = defaultLevel
+
+	var words []string
+	hasMessage := false
+	for rest := strings.TrimSpace(line); rest != ""; rest = strings.TrimLeft(rest, " \t") {
+		key, value, remaining, ok := nextKeyValue(rest)
+		if ok && key == LOGFMT_LEVEL_KEY {
+			// a level that is not known would clash with the level of the row, so it is kept as text
+			if _, known := levelOf(strings.ToLower(value)); !known {
+				ok = false
+			}
+		}
+
+		if !ok {
+			end := strings.IndexAny(rest, " \t")
+			if end < 0 {
+				end = len(rest)
+			}
+			words = append(words, rest[:end])
+			rest = rest[end:]
+			continue
+		}
+		rest = remaining
+
+		switch {
+		case key == LOGFMT_MESSAGE_KEY && !hasMessage:
+			message = value
+			hasMessage = true
+		case key == LOGFMT_LEVEL_KEY:
+			level = strings.ToLower(value)
+		default:
+			fields = append(fields, String(key, value))
+		}
+	}
+
+	if !hasMessage {
+		message = strings.Join(words, " ")
+	} else if len(words) > 0 {
+		fields = append(fields, String("text", strings.Join(words, " ")))
+	}
+
+	return level, message, fields
+}
+
+// ok is false if s does not start with a key=value pair
+func nextKeyValue(s string) (key string, value string, rest string, ok bool) {
+	eq := strings.IndexByte(s, '=')
+	if eq <= 0 || strings.ContainsAny(s[:eq], " \t\"") {
+		return "", "", s, false
+	}
+	key, rest = s[:eq], s[eq+1:]
+
+	if strings.HasPrefix(rest, `"`) {
+		for i := 1; i < len(rest); i++ {
+			switch rest[i] {
+			case '\\':
+				i++
+			case '"':
+				if unquoted, err := strconv.Unquote(rest[:i+1]); err == nil {
+					return key, unquoted, rest[i+1:], true
+				}
+				return key, rest[1:i], rest[i+1:], true
+			}
+		}
+		// an unterminated quote is taken as is
+		return key, rest, "", true
+	}
+
+	end := strings.IndexAny(rest, " \t")
+	if end < 0 {
+		end = len(rest)
+	}
+	return key, rest[:end], rest[end:], true
+}
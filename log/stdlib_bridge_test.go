@@ -0,0 +1,115 @@
+// Copyright 2019 the orbs-network-go authors
+// This file is part of the orbs-network-go library in the Orbs project.
+//
+// This source code is licensed under the MIT license found in the LICENSE file in the root directory of this source tree.
+// The above notice should be included in all copies or substantial portions of the software.
+
+package log
+
+import (
+	"fmt"
+	stdlog "log"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriter_LogsEveryLine(t *testing.T) {
+	b := &syncBuffer{}
+	w := NewLineWriter(GetLogger(Node("node1")).WithOutput(NewFormattingOutput(b, NewJsonFormatter())), "warn")
+
+	_, err := fmt.Fprint(w, "first line\r\nsecond ")
+	require.NoError(t, err)
+	_, err = fmt.Fprint(w, "line\n\nthird")
+	require.NoError(t, err)
+
+	rows := b.lines()
+	require.Len(t, rows, 2, "the third line has not ended yet")
+	require.Equal(t, "first line", rows[0]["message"])
+	require.Equal(t, "warn", rows[0]["level"])
+	require.Equal(t, "node1", rows[0]["node"])
+	require.Equal(t, "second line", rows[1]["message"])
+
+	w.Flush()
+	require.Equal(t, "third", b.lines()[2]["message"])
+}
+
+func TestWriter_SplitsLinesThatAreTooLong(t *testing.T) {
+	b := &syncBuffer{}
+	w := NewLineWriter(GetLogger().WithOutput(NewFormattingOutput(b, NewJsonFormatter())), "info")
+
+	_, err := w.Write([]byte(strings.Repeat("a", MAX_BRIDGED_LINE_LENGTH+10)))
+	require.NoError(t, err)
+
+	rows := b.lines()
+	require.Len(t, rows, 1)
+	require.Len(t, rows[0]["message"], MAX_BRIDGED_LINE_LENGTH)
+}
+
+func TestWriter_SplitsLongLinesBetweenRunes(t *testing.T) {
+	b := &syncBuffer{}
+	w := NewLineWriter(GetLogger().WithOutput(NewFormattingOutput(b, NewJsonFormatter())), "info")
+
+	// the last rune before the limit starts one byte before it and would be cut in half
+	_, err := w.Write([]byte(strings.Repeat("a", MAX_BRIDGED_LINE_LENGTH-1) + "é" + "tail"))
+	require.NoError(t, err)
+	w.Flush()
+
+	rows := b.lines()
+	require.Len(t, rows, 2)
+	require.Len(t, rows[0]["message"], MAX_BRIDGED_LINE_LENGTH-1)
+	require.Equal(t, "étail", rows[1]["message"])
+}
+
+func TestWriter_ReportsTheCodeThatWroteTheLine(t *testing.T) {
+	b := &syncBuffer{}
+	w := NewLineWriter(GetLogger().WithOutput(NewFormattingOutput(b, NewJsonFormatter())), "info")
+
+	_, err := fmt.Fprintln(w, "hello")
+	require.NoError(t, err)
+
+	require.Equal(t, "log.TestWriter_ReportsTheCodeThatWroteTheLine", b.lines()[0]["function"])
+	require.Regexp(t, "stdlib_bridge_test.go:\\d+$", b.lines()[0]["source"])
+}
+
+func TestWriter_ParsesKeyValues(t *testing.T) {
+	b := &syncBuffer{}
+	w := NewLineWriter(GetLogger().WithOutput(NewFormattingOutput(b, NewJsonFormatter())), "info").WithKeyValues()
+
+	_, err := fmt.Fprintln(w, `connection closed peer=10.0.0.1:4400 reason="read: connection reset" level=WARN`)
+	require.NoError(t, err)
+	_, err = fmt.Fprintln(w, `level=bogus msg="retrying soon" attempt=3 because why not`)
+	require.NoError(t, err)
+
+	rows := b.lines()
+	require.Equal(t, "connection closed", rows[0]["message"])
+	require.Equal(t, "warn", rows[0]["level"])
+	require.Equal(t, "10.0.0.1:4400", rows[0]["peer"])
+	require.Equal(t, "read: connection reset", rows[0]["reason"])
+
+	require.Equal(t, "retrying soon", rows[1]["message"])
+	require.Equal(t, "info", rows[1]["level"])
+	require.Equal(t, "3", rows[1]["attempt"])
+	require.Equal(t, "level=bogus because why not", rows[1]["text"], "an unknown level should be kept as text")
+}
+
+func TestRedirectStdLog(t *testing.T) {
+	defer func() {
+		stdlog.SetOutput(os.Stderr)
+		stdlog.SetFlags(stdlog.LstdFlags)
+	}()
+
+	b := &syncBuffer{}
+	RedirectStdLog(GetLogger().WithOutput(NewFormattingOutput(b, NewJsonFormatter()))).WithKeyValues()
+
+	stdlog.Printf("dialing peer=%s", "node2")
+
+	rows := b.lines()
+	require.Len(t, rows, 1)
+	require.Equal(t, "log.TestRedirectStdLog", rows[0]["function"])
+	require.Equal(t, "dialing", rows[0]["message"])
+	require.Equal(t, "info", rows[0]["level"])
+	require.Equal(t, "node2", rows[0]["peer"])
+}
@@ -0,0 +1,428 @@
+// Copyright 2019 the orbs-network-go authors
+// This file is part of the orbs-network-go library in the Orbs project.
+//
+// This source code is licensed under the MIT license found in the LICENSE file in the root directory of this source tree.
+// The above notice should be included in all copies or substantial portions of the software.
+
+package log
+
+import (
+	"crypto/tls"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+type SyslogFormat int
+
+const (
+	// SyslogFormatRFC5424 puts the row's fields in a structured data element
+	SyslogFormatRFC5424 SyslogFormat = iota
+	// SyslogFormatRFC3164 is the legacy BSD format, the row's fields are appended to the message in logfmt
+	SyslogFormatRFC3164
+)
+
+type SyslogFacility int
+
+const (
+	SYSLOG_FACILITY_USER   SyslogFacility = 1
+	SYSLOG_FACILITY_DAEMON SyslogFacility = 3
+	SYSLOG_FACILITY_LOCAL0 SyslogFacility = 16
+	SYSLOG_FACILITY_LOCAL1 SyslogFacility = 17
+	SYSLOG_FACILITY_LOCAL2 SyslogFacility = 18
+	SYSLOG_FACILITY_LOCAL3 SyslogFacility = 19
+	SYSLOG_FACILITY_LOCAL4 SyslogFacility = 20
+	SYSLOG_FACILITY_LOCAL5 SyslogFacility = 21
+	SYSLOG_FACILITY_LOCAL6 SyslogFacility = 22
+	SYSLOG_FACILITY_LOCAL7 SyslogFacility = 23
+)
+
+// RFC 5612 sets this enterprise number aside for documentation, which is as good as any for a library
+const SYSLOG_STRUCTURED_DATA_ID = "scribe@32473"
+
+const DEFAULT_SYSLOG_SOCKET = "/dev/log"
+
+const DEFAULT_SYSLOG_DIAL_TIMEOUT = 5 * time.Second
+const DEFAULT_SYSLOG_RECONNECT_BACKOFF = 100 * time.Millisecond
+const DEFAULT_SYSLOG_MAX_RECONNECT_BACKOFF = 30 * time.Second
+
+type SyslogOutputOptions struct {
+	// udp, tcp, tls, unix or unixgram; empty means the local syslog socket, over unixgram or unix, whichever it accepts
+	Network string
+	// host:port, or the socket path for unix networks (DEFAULT_SYSLOG_SOCKET if empty)
+	Address string
+	// used when Network is tls
+	TLSConfig *tls.Config
+	Format    SyslogFormat
+	// 0 means SYSLOG_FACILITY_USER
+	Facility SyslogFacility
+	// defaults to the name of the executable
+	AppName string
+	// defaults to os.Hostname
+	Hostname string
+	// bounds how long connecting may block the caller that logs; DEFAULT_SYSLOG_DIAL_TIMEOUT if not positive
+	DialTimeout time.Duration
+	// after a failed connect, rows are dropped and counted for this long before connecting is tried again, doubling with every
+	// failure up to MaxReconnectBackoff; DEFAULT_SYSLOG_RECONNECT_BACKOFF and DEFAULT_SYSLOG_MAX_RECONNECT_BACKOFF if not positive
+	ReconnectBackoff    time.Duration
+	MaxReconnectBackoff time.Duration
+}
+
+type syslogOutput struct {
+	opts      SyslogOutputOptions
+	formatter *syslogFormatter
+	filters   and
+	levelThreshold
+
+	lock      sync.Mutex
+	conn      net.Conn
+	network   string // the one that was dialed, which tells how messages are framed
+	closed    bool
+	backoff   time.Duration // how long to wait after the next failed connect
+	reconnect time.Time     // rows are dropped until then
+	dropped   uint64
+}
+
+// NewSyslogOutput writes rows to a syslog daemon, reconnecting whenever a write fails. while the daemon can't be reached,
+// rows are dropped instead of stalling callers, and how many were dropped is sent as a metric row once it can
+func NewSyslogOutput(opts SyslogOutputOptions) (*syslogOutput, error) {
+	if opts.Facility == 0 {
+		opts.Facility = SYSLOG_FACILITY_USER
+	}
+
+	if opts.AppName == "" {
+		opts.AppName = filepath.Base(os.Args[0])
+	}
+
+	if opts.Hostname == "" {
+		opts.Hostname, _ = os.Hostname()
+	}
+
+	if opts.Address == "" && (opts.Network == "" || opts.Network == "unix" || opts.Network == "unixgram") {
+		opts.Address = DEFAULT_SYSLOG_SOCKET
+	}
+
+	if opts.DialTimeout <= 0 {
+		opts.DialTimeout = DEFAULT_SYSLOG_DIAL_TIMEOUT
+	}
+
+	if opts.ReconnectBackoff <= 0 {
+		opts.ReconnectBackoff = DEFAULT_SYSLOG_RECONNECT_BACKOFF
+	}
+
+	if opts.MaxReconnectBackoff <= 0 {
+		opts.MaxReconnectBackoff = DEFAULT_SYSLOG_MAX_RECONNECT_BACKOFF
+	}
+
+	out := &syslogOutput{
+		opts:    opts,
+		backoff: opts.ReconnectBackoff,
+		formatter: &syslogFormatter{
+			format:   opts.Format,
+			facility: opts.Facility,
+			hostname: opts.Hostname,
+			appName:  opts.AppName,
+			pid:      os.Getpid(),
+		},
+	}
+
+	if err := out.connect(); err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// assumes lock
+func (out *syslogOutput) connect() error {
+	var err error
+
+	switch out.opts.Network {
+	case "":
+		for _, network := range []string{"unixgram", "unix"} {
+			if out.conn, err = net.DialTimeout(network, out.opts.Address, out.opts.DialTimeout); err == nil {
+				out.network = network
+				return nil
+			}
+		}
+		return err
+	case "tls":
+		out.conn, err = tls.DialWithDialer(&net.Dialer{Timeout: out.opts.DialTimeout}, "tcp", out.opts.Address, out.opts.TLSConfig)
+	default:
+		out.conn, err = net.DialTimeout(out.opts.Network, out.opts.Address, out.opts.DialTimeout)
+	}
+
+	out.network = out.opts.Network
+	return err
+}
+
+func (out *syslogOutput) SetFilters(filters ...Filter) {
+	out.filters = and{filters}
+}
+
+func (out *syslogOutput) formatsAggregates() bool {
+	return true
+}
+
+func (out *syslogOutput) Append(onError func(err error), level string, message string, fields ...*Field) {
+	out.appendAt(onError, time.Now(), level, message, fields...)
+}
+
+func (out *syslogOutput) appendAt(onError func(err error), timestamp time.Time, level string, message string, fields ...*Field) {
+	if !out.levelThreshold.allows(level) || !out.filters.Allows(level, message, fields) {
+		return
+	}
+
+	row := out.formatter.FormatRow(timestamp, level, message, fields...)
+
+	if err := out.write(row); err != nil {
+		onError(err)
+	}
+}
+
+// a write that fails is retried once on a new connection
+func (out *syslogOutput) write(row string) error {
+	out.lock.Lock()
+	defer out.lock.Unlock()
+
+	if out.closed {
+		return errors.Errorf("syslog output is closed")
+	}
+
+	if out.conn != nil {
+		if _, err := out.conn.Write(out.frame(row)); err == nil {
+			return nil
+		}
+		_ = out.conn.Close()
+		out.conn = nil
+	}
+
+	// only the row that tries to connect reports the failure, the ones dropped until the next try are counted
+	if time.Now().Before(out.reconnect) {
+		out.dropped++
+		return nil
+	}
+
+	if err := out.connect(); err != nil {
+		out.conn = nil
+		out.dropped++
+		out.reconnect = time.Now().Add(out.backoff)
+		if out.backoff *= 2; out.backoff > out.opts.MaxReconnectBackoff {
+			out.backoff = out.opts.MaxReconnectBackoff
+		}
+		return errors.Errorf("failed to connect to syslog at %s: %s", out.opts.Address, err)
+	}
+	out.backoff = out.opts.ReconnectBackoff
+
+	if out.dropped > 0 {
+		report := out.formatter.FormatRow(time.Now(), "metric", "Metric recorded", Uint64("syslog-output-dropped-rows", out.dropped))
+		if _, err := out.conn.Write(out.frame(report)); err == nil {
+			out.dropped = 0
+		}
+	}
+
+	if _, err := out.conn.Write(out.frame(row)); err != nil {
+		_ = out.conn.Close()
+		out.conn = nil
+		return errors.Errorf("failed to write to syslog at %s: %s", out.opts.Address, err)
+	}
+
+	return nil
+}
+
+// stream transports need to delimit messages: tcp and tls use octet counting (RFC 6587), unix streams a trailing newline.
+// assumes lock
+func (out *syslogOutput) frame(row string) []byte {
+	switch out.network {
+	case "tcp", "tls":
+		return []byte(strconv.Itoa(len(row)) + SPACE + row)
+	case "unix":
+		return []byte(row + "\n")
+	default:
+		return []byte(row)
+	}
+}
+
+func (out *syslogOutput) Close() error {
+	out.lock.Lock()
+	defer out.lock.Unlock()
+
+	out.closed = true
+	if out.conn == nil {
+		return nil
+	}
+
+	err := out.conn.Close()
+	out.conn = nil
+	return err
+}
+
+type syslogFormatter struct {
+	format   SyslogFormat
+	facility SyslogFacility
+	hostname string
+	appName  string
+	pid      int
+}
+
+// levels that are not severities, such as metrics, are sent as informational
+func syslogSeverity(level string) int {
+	l, ok := levelOf(level)
+	if !ok {
+		return 6
+	}
+
+	switch l {
+	case TraceLevel, DebugLevel:
+		return 7
+	case InfoLevel:
+		return 6
+	case WarnLevel:
+		return 4
+	case ErrorLevel:
+		return 3
+	default:
+		return 2
+	}
+}
+
+func (f *syslogFormatter) FormatRow(timestamp time.Time, level string, message string, params ...*Field) (formattedRow string) {
+	builder := strings.Builder{}
+
+	builder.WriteString("<")
+	builder.WriteString(strconv.Itoa(int(f.facility)*8 + syslogSeverity(level)))
+	builder.WriteString(">")
+
+	if f.format == SyslogFormatRFC3164 {
+		f.writeRFC3164(&builder, timestamp, message, params)
+	} else {
+		f.writeRFC5424(&builder, timestamp, message, params)
+	}
+
+	return builder.String()
+}
+
+func (f *syslogFormatter) writeRFC5424(builder *strings.Builder, timestamp time.Time, message string, params []*Field) {
+	builder.WriteString("1 ")
+	builder.WriteString(timestamp.UTC().Format("2006-01-02T15:04:05.000000Z"))
+	builder.WriteString(SPACE)
+	builder.WriteString(syslogHeaderField(f.hostname, 255))
+	builder.WriteString(SPACE)
+	builder.WriteString(syslogHeaderField(f.appName, 48))
+	builder.WriteString(SPACE)
+	builder.WriteString(strconv.Itoa(f.pid))
+	builder.WriteString(" - ")
+
+	if len(params) == 0 {
+		builder.WriteString("-")
+	} else {
+		builder.WriteString("[" + SYSLOG_STRUCTURED_DATA_ID)
+		writeStructuredData(builder, "", params)
+		builder.WriteString("]")
+	}
+
+	if message != "" {
+		builder.WriteString(SPACE)
+		builder.WriteString(message)
+	}
+}
+
+func writeStructuredData(builder *strings.Builder, prefix string, params []*Field) {
+	for _, param := range params {
+		if param == nil {
+			continue
+		}
+
+		if param.IsNested() {
+			writeStructuredData(builder, prefix+param.Key+".", param.Nested.NestedFields())
+			continue
+		}
+
+		builder.WriteString(SPACE)
+		builder.WriteString(structuredDataName(prefix + param.Key))
+		builder.WriteString(`="`)
+		builder.WriteString(structuredDataEscaper.Replace(logfmtValue(param)))
+		builder.WriteString(`"`)
+	}
+}
+
+var structuredDataEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`)
+
+// param names are 1 to 32 printable ascii characters other than '=', ' ', ']' and '"'
+func structuredDataName(key string) string {
+	name := []byte(key)
+	for i, c := range name {
+		if c <= ' ' || c > '~' || c == '=' || c == ']' || c == '"' {
+			name[i] = '_'
+		}
+	}
+
+	if len(name) == 0 {
+		return "_"
+	}
+	if len(name) > 32 {
+		name = name[:32]
+	}
+
+	return string(name)
+}
+
+// header fields are printable ascii without spaces, and "-" when empty
+func syslogHeaderField(value string, maxLength int) string {
+	field := []byte(value)
+	for i, c := range field {
+		if c <= ' ' || c > '~' {
+			field[i] = '_'
+		}
+	}
+
+	if len(field) == 0 {
+		return "-"
+	}
+	if len(field) > maxLength {
+		field = field[:maxLength]
+	}
+
+	return string(field)
+}
+
+func (f *syslogFormatter) writeRFC3164(builder *strings.Builder, timestamp time.Time, message string, params []*Field) {
+	builder.WriteString(timestamp.Format(time.Stamp))
+	builder.WriteString(SPACE)
+	builder.WriteString(syslogHeaderField(f.hostname, 255))
+	builder.WriteString(SPACE)
+	builder.WriteString(syslogTag(f.appName))
+	builder.WriteString("[")
+	builder.WriteString(strconv.Itoa(f.pid))
+	builder.WriteString("]: ")
+	builder.WriteString(message)
+
+	if len(params) > 0 {
+		fields := strings.Builder{}
+		writeLogfmtFields(&fields, "", params)
+		builder.WriteString(SPACE)
+		builder.WriteString(fields.String())
+	}
+}
+
+// the tag is up to 32 alphanumeric characters, other characters would be taken for the start of the message
+func syslogTag(appName string) string {
+	tag := []byte(appName)
+	for i, c := range tag {
+		if !(c >= 'a' && c <= 'z') && !(c >= 'A' && c <= 'Z') && !(c >= '0' && c <= '9') && c != '-' && c != '_' && c != '.' {
+			tag[i] = '_'
+		}
+	}
+
+	if len(tag) > 32 {
+		tag = tag[:32]
+	}
+
+	return string(tag)
+}
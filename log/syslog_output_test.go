@@ -0,0 +1,204 @@
+// Copyright 2019 the orbs-network-go authors
+// This file is part of the orbs-network-go library in the Orbs project.
+//
+// This source code is licensed under the MIT license found in the LICENSE file in the root directory of this source tree.
+// The above notice should be included in all copies or substantial portions of the software.
+
+package log
+
+import (
+	"bufio"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func testSyslogFormatter(format SyslogFormat) *syslogFormatter {
+	return &syslogFormatter{format: format, facility: SYSLOG_FACILITY_LOCAL0, hostname: "host1", appName: "orbs-node", pid: 42}
+}
+
+func TestSyslogFormatter_RFC5424(t *testing.T) {
+	tm, err := time.Parse(TIMESTAMP_FORMAT, "2006-01-02T01:23:45.123456789Z")
+	require.NoError(t, err)
+
+	row := testSyslogFormatter(SyslogFormatRFC5424).FormatRow(tm, "warn", "foo bar",
+		Node("node1"), String("quote", `a "b" [c]`), Aggregate("music", String("Artist", "Iggy Pop")))
+
+	require.Equal(t, `<132>1 2006-01-02T01:23:45.123456Z host1 orbs-node 42 - [scribe@32473 node="node1" quote="a \"b\" [c\]" music.Artist="Iggy Pop"] foo bar`, row)
+}
+
+func TestSyslogFormatter_RFC5424WithoutFields(t *testing.T) {
+	tm, err := time.Parse(TIMESTAMP_FORMAT, "2006-01-02T01:23:45Z")
+	require.NoError(t, err)
+
+	row := testSyslogFormatter(SyslogFormatRFC5424).FormatRow(tm, "metric", "foo")
+
+	require.Equal(t, "<134>1 2006-01-02T01:23:45.000000Z host1 orbs-node 42 - - foo", row)
+}
+
+func TestSyslogFormatter_RFC3164(t *testing.T) {
+	tm := time.Date(2006, 1, 2, 1, 23, 45, 0, time.Local)
+
+	row := testSyslogFormatter(SyslogFormatRFC3164).FormatRow(tm, "error", "foo bar", Node("node1"), Int("vcid", 42))
+
+	require.Equal(t, "<131>Jan  2 01:23:45 host1 orbs-node[42]: foo bar node=node1 vcid=42", row)
+}
+
+func TestSyslogSeverity(t *testing.T) {
+	for level, severity := range map[string]int{"trace": 7, "debug": 7, "info": 6, "warn": 4, "error": 3, "fatal": 2, "metric": 6} {
+		require.Equal(t, severity, syslogSeverity(level), level)
+	}
+}
+
+func TestSyslogOutput_UDP(t *testing.T) {
+	conn := listenUDP(t)
+	defer conn.Close()
+
+	output, err := NewSyslogOutput(SyslogOutputOptions{Network: "udp", Address: conn.LocalAddr().String(), AppName: "orbs-node", Hostname: "host1"})
+	require.NoError(t, err)
+	defer output.Close()
+
+	GetLogger().WithOutput(output).Info("foo")
+
+	require.Regexp(t, `^<14>1 \S+ host1 orbs-node \d+ - \[scribe@32473 function="log.TestSyslogOutput_UDP" source="\S+"\] foo$`, readPacket(t, conn))
+}
+
+func TestSyslogOutput_UnixDatagram(t *testing.T) {
+	dir, err := ioutil.TempDir("", "syslog")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "log.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	require.NoError(t, err)
+	defer conn.Close()
+
+	output, err := NewSyslogOutput(SyslogOutputOptions{Address: path, Format: SyslogFormatRFC3164, AppName: "orbs-node", Hostname: "host1"})
+	require.NoError(t, err)
+	defer output.Close()
+
+	output.Append(onErrorStub, "info", "foo")
+
+	buf := make([]byte, 1024)
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+	n, err := conn.Read(buf)
+	require.NoError(t, err)
+	require.Regexp(t, `^<14>\w{3} [ \d]\d \d\d:\d\d:\d\d host1 orbs-node\[\d+\]: foo$`, string(buf[:n]))
+}
+
+func readOctetCountedFrame(t *testing.T, r *bufio.Reader) string {
+	length, err := r.ReadString(' ')
+	require.NoError(t, err)
+	n, err := strconv.Atoi(strings.TrimSpace(length))
+	require.NoError(t, err)
+
+	frame := make([]byte, n)
+	_, err = r.Read(frame)
+	require.NoError(t, err)
+	return string(frame)
+}
+
+func TestSyslogOutput_TCPReconnectsWhenConnectionDrops(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	output, err := NewSyslogOutput(SyslogOutputOptions{Network: "tcp", Address: listener.Addr().String()})
+	require.NoError(t, err)
+	defer output.Close()
+
+	first, err := listener.Accept()
+	require.NoError(t, err)
+
+	output.Append(onErrorStub, "info", "foo")
+	require.Regexp(t, "foo$", readOctetCountedFrame(t, bufio.NewReader(first)))
+
+	require.NoError(t, first.Close())
+
+	// the first write after the peer closed may still succeed locally, so keep writing until a new connection shows up
+	accepted := make(chan net.Conn)
+	go func() {
+		conn, err := listener.Accept()
+		if err == nil {
+			accepted <- conn
+		}
+	}()
+
+	var second net.Conn
+	for second == nil {
+		output.Append(onErrorStub, "info", "bar")
+		select {
+		case second = <-accepted:
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	defer second.Close()
+
+	require.Regexp(t, "bar$", readOctetCountedFrame(t, bufio.NewReader(second)))
+}
+
+func TestSyslogOutput_DropsRowsWhileBackingOffFromReconnecting(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	address := listener.Addr().String()
+
+	output, err := NewSyslogOutput(SyslogOutputOptions{Network: "tcp", Address: address, ReconnectBackoff: time.Hour})
+	require.NoError(t, err)
+	defer output.Close()
+
+	first, err := listener.Accept()
+	require.NoError(t, err)
+	require.NoError(t, first.Close())
+	require.NoError(t, listener.Close())
+
+	// the first writes after the peer closed may still succeed locally
+	var reported error
+	for reported == nil {
+		output.Append(func(err error) { reported = err }, "info", "foo")
+	}
+	require.Regexp(t, "failed to connect", reported.Error())
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		output.Append(func(err error) { require.NoError(t, err) }, "info", "dropped")
+	}
+	require.True(t, time.Since(start) < time.Second, "rows should be dropped without trying to connect")
+
+	listener, err = net.Listen("tcp", address)
+	require.NoError(t, err)
+	defer listener.Close()
+
+	output.lock.Lock()
+	require.EqualValues(t, 4, output.dropped)
+	output.reconnect = time.Time{}
+	output.lock.Unlock()
+
+	output.Append(onErrorStub, "info", "bar")
+	second, err := listener.Accept()
+	require.NoError(t, err)
+	defer second.Close()
+
+	r := bufio.NewReader(second)
+	require.Regexp(t, `syslog-output-dropped-rows="4"`, readOctetCountedFrame(t, r))
+	require.Regexp(t, "bar$", readOctetCountedFrame(t, r))
+}
+
+func TestSyslogOutput_ReportsErrorsAfterClose(t *testing.T) {
+	conn := listenUDP(t)
+	defer conn.Close()
+
+	output, err := NewSyslogOutput(SyslogOutputOptions{Network: "udp", Address: conn.LocalAddr().String()})
+	require.NoError(t, err)
+	require.NoError(t, output.Close())
+
+	var reported error
+	output.Append(func(err error) { reported = err }, "info", "foo")
+	require.EqualError(t, reported, "syslog output is closed")
+}
@@ -25,7 +25,7 @@ func (o *TestOutput) Append(onError func(err error), level string, message strin
 
 	logLine := o.formatter.FormatRow(time.Now(), level, message, fields...)
 
-	if level == "error" && !o.allowed(message, fields) {
+	if isErrorLevel(level) && !o.allowed(message, fields) {
 		o.disableLogging()
 		o.recordError(logLine)
 	} else {
@@ -43,18 +43,20 @@ type TestOutput struct {
 func (o *TestOutput) SetFilters(_ ...Filter) {
 }
 
+func (o *TestOutput) formatsAggregates() bool {
+	return formatsAggregates(o.formatter)
+}
+
 // assumes read lock (o.RLock())
 func (o *TestOutput) allowed(message string, fields []*Field) bool {
 	for _, allowedPattern := range o.allowedErrorPatterns {
 		if allowedPattern.MatchString(message) {
 			return true
 		}
-		for _, f := range fields {
-			if f.Key == "error" {
-				if allowedPattern.MatchString(f.String()) {
-					return true
-				}
-			}
+		if findField(fields, func(f *Field) bool {
+			return f.Key == "error" && allowedPattern.MatchString(f.String())
+		}) != nil {
+			return true
 		}
 	}
 
@@ -0,0 +1,164 @@
+// Copyright 2019 the orbs-network-go authors
+// This file is part of the orbs-network-go library in the Orbs project.
+//
+// This source code is licensed under the MIT license found in the LICENSE file in the root directory of this source tree.
+// The above notice should be included in all copies or substantial portions of the software.
+
+package log
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+)
+
+const TRACEPARENT_HEADER = "traceparent"
+
+const TRACE_FLAG_SAMPLED = 0x01
+
+// TraceContext identifies a span of a distributed trace, as carried by the W3C traceparent header
+type TraceContext struct {
+	TraceID [16]byte
+	SpanID  [8]byte
+	Flags   byte
+}
+
+func (tc TraceContext) IsValid() bool {
+	return tc.TraceID != [16]byte{} && tc.SpanID != [8]byte{}
+}
+
+func (tc TraceContext) IsSampled() bool {
+	return tc.Flags&TRACE_FLAG_SAMPLED != 0
+}
+
+func (tc TraceContext) TraceIDString() string {
+	return hex.EncodeToString(tc.TraceID[:])
+}
+
+func (tc TraceContext) SpanIDString() string {
+	return hex.EncodeToString(tc.SpanID[:])
+}
+
+func (tc TraceContext) FlagsString() string {
+	return fmt.Sprintf("%02x", tc.Flags)
+}
+
+// Traceparent formats tc as a version 00 traceparent header value
+func (tc TraceContext) Traceparent() string {
+	return "00-" + tc.TraceIDString() + "-" + tc.SpanIDString() + "-" + tc.FlagsString()
+}
+
+// NewChildSpan returns a context for a new span within the same trace
+func (tc TraceContext) NewChildSpan() TraceContext {
+	child := tc
+	child.SpanID = randomSpanID()
+	return child
+}
+
+// NewTraceContext starts a new sampled trace, for callers at the edge of the system that received no traceparent
+func NewTraceContext() TraceContext {
+	tc := TraceContext{SpanID: randomSpanID(), Flags: TRACE_FLAG_SAMPLED}
+	for tc.TraceID == [16]byte{} {
+		_, _ = rand.Read(tc.TraceID[:])
+	}
+	return tc
+}
+
+func randomSpanID() (id [8]byte) {
+	for id == [8]byte{} {
+		_, _ = rand.Read(id[:])
+	}
+	return
+}
+
+// ParseTraceparent parses a W3C traceparent header value (version-traceid-spanid-flags).
+// versions after 00 may append further fields, which are ignored
+func ParseTraceparent(value string) (TraceContext, error) {
+	var tc TraceContext
+
+	if len(value) < 55 || (len(value) > 55 && (value[:2] == "00" || value[55] != '-')) {
+		return tc, errors.Errorf("invalid traceparent %s", value)
+	}
+	if value[2] != '-' || value[35] != '-' || value[52] != '-' {
+		return tc, errors.Errorf("invalid traceparent %s", value)
+	}
+
+	var version [1]byte
+	if err := decodeLowerHex(version[:], value[0:2]); err != nil || version[0] == 0xff {
+		return tc, errors.Errorf("invalid traceparent version in %s", value)
+	}
+	if err := decodeLowerHex(tc.TraceID[:], value[3:35]); err != nil {
+		return tc, errors.Errorf("invalid trace id in traceparent %s", value)
+	}
+	if err := decodeLowerHex(tc.SpanID[:], value[36:52]); err != nil {
+		return tc, errors.Errorf("invalid span id in traceparent %s", value)
+	}
+
+	var flags [1]byte
+	if err := decodeLowerHex(flags[:], value[53:55]); err != nil {
+		return tc, errors.Errorf("invalid trace flags in traceparent %s", value)
+	}
+	tc.Flags = flags[0]
+
+	if !tc.IsValid() {
+		return tc, errors.Errorf("traceparent %s has an all-zero trace or span id", value)
+	}
+
+	return tc, nil
+}
+
+// the spec only allows lowercase hex, which hex.Decode does not enforce
+func decodeLowerHex(dst []byte, src string) error {
+	for _, c := range src {
+		if !(c >= '0' && c <= '9') && !(c >= 'a' && c <= 'f') {
+			return errors.Errorf("invalid hex character %q", c)
+		}
+	}
+
+	_, err := hex.Decode(dst, []byte(src))
+	return err
+}
+
+// TraceFields returns the trace_id, span_id and trace_flags fields of tc
+func TraceFields(tc TraceContext) []*Field {
+	return []*Field{TraceID(tc.TraceIDString()), SpanID(tc.SpanIDString()), TraceFlags(tc.FlagsString())}
+}
+
+type traceContextKey struct{}
+
+// ContextWithTrace returns a copy of ctx that carries tc, for callers that do not use an OpenTelemetry SDK
+func ContextWithTrace(ctx context.Context, tc TraceContext) context.Context {
+	return context.WithValue(ctx, traceContextKey{}, tc)
+}
+
+// TraceExtractor finds the current span on a context
+type TraceExtractor func(ctx context.Context) (TraceContext, bool)
+
+var traceExtractor atomic.Value
+
+// SetTraceExtractor replaces the way the *Ctx logger methods find the current span.
+// OpenTelemetry users can convert the span context of their SDK here; the default reads what ContextWithTrace stored
+func SetTraceExtractor(extractor TraceExtractor) {
+	traceExtractor.Store(extractor)
+}
+
+// TraceFromContext returns the current span on ctx, as found by the extractor set by SetTraceExtractor
+func TraceFromContext(ctx context.Context) (TraceContext, bool) {
+	if ctx == nil {
+		return TraceContext{}, false
+	}
+
+	if extractor, ok := traceExtractor.Load().(TraceExtractor); ok && extractor != nil {
+		if tc, ok := extractor(ctx); ok && tc.IsValid() {
+			return tc, true
+		}
+		return TraceContext{}, false
+	}
+
+	tc, ok := ctx.Value(traceContextKey{}).(TraceContext)
+	return tc, ok && tc.IsValid()
+}
@@ -0,0 +1,122 @@
+// Copyright 2019 the orbs-network-go authors
+// This file is part of the orbs-network-go library in the Orbs project.
+//
+// This source code is licensed under the MIT license found in the LICENSE file in the root directory of this source tree.
+// The above notice should be included in all copies or substantial portions of the software.
+
+package log
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testTraceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+func TestParseTraceparent(t *testing.T) {
+	tc, err := ParseTraceparent(testTraceparent)
+	require.NoError(t, err)
+
+	require.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", tc.TraceIDString())
+	require.Equal(t, "00f067aa0ba902b7", tc.SpanIDString())
+	require.True(t, tc.IsSampled())
+	require.Equal(t, testTraceparent, tc.Traceparent())
+}
+
+func TestParseTraceparent_AcceptsFieldsAddedByLaterVersions(t *testing.T) {
+	tc, err := ParseTraceparent("01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00-extra")
+	require.NoError(t, err)
+	require.False(t, tc.IsSampled())
+}
+
+func TestParseTraceparent_RejectsInvalidValues(t *testing.T) {
+	for _, value := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00_4bf92f3577b34da6a3ce929d0e0e4736_00f067aa0ba902b7_01",
+	} {
+		_, err := ParseTraceparent(value)
+		require.Error(t, err, "parsed %s", value)
+	}
+}
+
+func TestNewTraceContext(t *testing.T) {
+	tc := NewTraceContext()
+	require.True(t, tc.IsValid())
+	require.True(t, tc.IsSampled())
+
+	parsed, err := ParseTraceparent(tc.Traceparent())
+	require.NoError(t, err)
+	require.Equal(t, tc, parsed)
+
+	child := tc.NewChildSpan()
+	require.Equal(t, tc.TraceID, child.TraceID)
+	require.NotEqual(t, tc.SpanID, child.SpanID)
+}
+
+func TestInfoCtx_AddsTraceFields(t *testing.T) {
+	tc, _ := ParseTraceparent(testTraceparent)
+	b := new(bytes.Buffer)
+	logger := GetLogger().WithOutput(NewFormattingOutput(b, NewJsonFormatter()))
+
+	logger.InfoCtx(ContextWithTrace(context.Background(), tc), "foo")
+
+	jsonMap := parseOutput(b.String())
+	require.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", jsonMap["trace_id"])
+	require.Equal(t, "00f067aa0ba902b7", jsonMap["span_id"])
+	require.Equal(t, "01", jsonMap["trace_flags"])
+}
+
+func TestInfoCtx_WithoutSpanHasNoTraceFields(t *testing.T) {
+	b := new(bytes.Buffer)
+	GetLogger().WithOutput(NewFormattingOutput(b, NewJsonFormatter())).InfoCtx(context.Background(), "foo")
+
+	require.NotContains(t, parseOutput(b.String()), "trace_id")
+}
+
+func TestSetTraceExtractor(t *testing.T) {
+	tc, _ := ParseTraceparent(testTraceparent)
+	SetTraceExtractor(func(ctx context.Context) (TraceContext, bool) {
+		return tc, true
+	})
+	defer SetTraceExtractor(nil)
+
+	b := new(bytes.Buffer)
+	GetLogger().WithOutput(NewFormattingOutput(b, NewJsonFormatter())).InfoCtx(context.Background(), "foo")
+
+	require.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", parseOutput(b.String())["trace_id"])
+}
+
+func TestJsonFormatter_WithTraceKeys(t *testing.T) {
+	tc, _ := ParseTraceparent(testTraceparent)
+	row := NewJsonFormatter().WithTraceKeys("trace.id", "span.id", "trace.flags").FormatRow(time.Now(), "info", "foo", TraceFields(tc)...)
+
+	jsonMap := parseOutput(row)
+	require.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", jsonMap["trace.id"])
+	require.Equal(t, "00f067aa0ba902b7", jsonMap["span.id"])
+	require.Equal(t, "01", jsonMap["trace.flags"])
+	require.NotContains(t, jsonMap, "trace_id")
+}
+
+func TestHumanReadableFormatter_ColorsRowsByTraceID(t *testing.T) {
+	f := NewHumanReadableFormatter()
+	tc := NewTraceContext()
+
+	first := f.FormatRow(time.Now(), "info", "foo", TraceFields(tc)...)
+	second := f.FormatRow(time.Now(), "info", "bar", TraceFields(tc.NewChildSpan())...)
+
+	firstColor := first[:strings.Index(first, "i ")]
+	secondColor := second[:strings.Index(second, "i ")]
+	require.NotEmpty(t, firstColor, "row was not colored")
+	require.Equal(t, firstColor, secondColor, "rows of the same trace should share a color")
+}